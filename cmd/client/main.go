@@ -13,6 +13,7 @@ import (
 	"github.com/LeoCommon/client/internal/client/api/helpers"
 	jwt "github.com/LeoCommon/client/internal/client/api/jwt/misc"
 	"github.com/LeoCommon/client/internal/client/constants"
+	"github.com/LeoCommon/client/internal/client/control"
 	"github.com/LeoCommon/client/internal/client/task/handler"
 	"github.com/LeoCommon/client/pkg/log"
 	"github.com/LeoCommon/client/pkg/system/cli"
@@ -92,6 +93,13 @@ func main() {
 		return
 	}
 
+	// Local administration with clientctl, the client works without it
+	clientConf := app.Conf.Client().C()
+	ctl, err := control.Listen(clientConf.ControlSocketPath(), clientConf.ControlGroup, handler)
+	if err != nil {
+		log.Error("control socket not available", zap.Error(err))
+	}
+
 	// At this point the app struct is filled, and we can use it
 	jobTicker := time.NewTicker(time.Duration(app.Conf.Job().C().PollingInterval))
 	app.WG.Add(1)
@@ -102,16 +110,12 @@ func main() {
 	// Attention: "tick shifts"
 	// If the execution takes more time, consequent runs are delayed.
 	go func() {
-		IsRebootPending := func(skipHandler bool) bool {
-			// Check if the reboot marker exists and if we can safely reboot
+		IsRebootPending := func() bool {
+			// Check if the reboot marker exists, running jobs get the drain timeout to finish
 			if rebootMarkerExists() {
-				log.Info("Reboot marker detected")
-
-				if skipHandler || !handler.HasRunningJob() {
-					log.Info("Preparing soft-reboot", zap.Bool("SkipHandler", skipHandler))
-					rebootCMD = cli.PrepareSoftReboot()
-					return true
-				}
+				log.Info("Reboot marker detected, preparing soft-reboot")
+				rebootCMD = cli.PrepareSoftReboot()
+				return true
 			}
 
 			return false
@@ -123,7 +127,7 @@ func main() {
 		}
 
 		// Check if we have an imminent reboot this early
-		if IsRebootPending(true) {
+		if IsRebootPending() {
 			log.Info("Skipping checkin, terminating early ...")
 			TerminateLoop()
 			return
@@ -157,7 +161,7 @@ func main() {
 				systemd.EntertainWatchdog()
 
 				// This is just in case we missed a signal
-				if IsRebootPending(false) {
+				if IsRebootPending() {
 					TerminateLoop()
 					return
 				}
@@ -183,7 +187,7 @@ func main() {
 
 				systemd.EntertainWatchdog()
 
-				if IsRebootPending(false) {
+				if IsRebootPending() {
 					TerminateLoop()
 					return
 				}
@@ -192,7 +196,7 @@ func main() {
 				log.Info("exit signal received - shutting down tasks and routines")
 
 				systemd.EntertainWatchdog()
-				IsRebootPending(true)
+				IsRebootPending()
 				TerminateLoop()
 				return
 			}
@@ -204,6 +208,14 @@ func main() {
 
 	log.Info("pending tasks and routines terminated")
 
+	if ctl != nil {
+		_ = ctl.Close()
+	}
+
+	// Stop accepting jobs and let the running ones finish before the services go away
+	systemd.EntertainWatchdog()
+	handler.Drain()
+
 	// Shutdown everything
 	app.Shutdown()
 
@@ -0,0 +1,291 @@
+package main
+
+// clientctl talks to the running client over its control socket
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/internal/client/control"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const usage = `usage: clientctl [flags] <command> [args]
+
+commands:
+  tasks              list the queued, running and recurring tasks
+  submit <file|->    submit a local job, a JSON job like the server sends it
+  check <file|->     check if a job could run right now without running it
+  cancel <id>        cancel all tasks of a job
+  checkin            check in with the server right away
+  poll               poll the jobs from the server right away
+  config             print the effective configuration, credentials are masked
+  health             show the state of GNSS, network, OTA, USB and the scheduler
+
+flags:
+`
+
+func main() {
+	socket := flag.String("socket", "", "path of the control socket, defaults to the one of the config file")
+	configPath := flag.String("config", config.DefaultConfigPath, "config file of the client, used to find the control socket")
+	asJSON := flag.Bool("json", false, "print the raw JSON responses")
+	timeout := flag.Duration("timeout", 30*time.Second, "give up on requests after this long")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if len(*socket) == 0 {
+		*socket = socketPath(*configPath)
+	}
+
+	c := control.NewClient(*socket, *timeout)
+	if err := run(c, flag.Arg(0), flag.Args()[1:], *asJSON); err != nil {
+		fmt.Fprintln(os.Stderr, "clientctl:", err)
+		os.Exit(1)
+	}
+}
+
+// socketPath reads the control socket from the config file, the default is used if it can not be read
+func socketPath(configPath string) string {
+	var conf config.MainConfig
+	if data, err := os.ReadFile(configPath); err == nil {
+		_ = toml.Unmarshal(data, &conf)
+	}
+
+	return conf.Client.ControlSocketPath()
+}
+
+func run(c *control.Client, command string, args []string, asJSON bool) error {
+	switch command {
+	case "tasks":
+		snap, err := c.Tasks()
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(snap)
+		}
+		printTasks(os.Stdout, snap)
+
+	case "submit":
+		if len(args) != 1 {
+			return fmt.Errorf("submit needs a job file, - reads it from stdin")
+		}
+		job, err := readJob(args[0])
+		if err != nil {
+			return err
+		}
+
+		job, err = c.Submit(job)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(job)
+		}
+		fmt.Println("submitted", job.Id)
+
+	case "check":
+		if len(args) != 1 {
+			return fmt.Errorf("check needs a job file, - reads it from stdin")
+		}
+		job, err := readJob(args[0])
+		if err != nil {
+			return err
+		}
+
+		verdict, err := c.Check(job)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			err = printJSON(verdict)
+		} else {
+			printVerdict(os.Stdout, verdict)
+		}
+		if err == nil && !verdict.Runnable {
+			err = fmt.Errorf("job can not run")
+		}
+		return err
+
+	case "cancel":
+		if len(args) != 1 {
+			return fmt.Errorf("cancel needs a job id")
+		}
+		if err := c.Cancel(args[0]); err != nil {
+			return err
+		}
+		fmt.Println("cancelled", args[0])
+
+	case "checkin":
+		if err := c.Checkin(); err != nil {
+			return err
+		}
+		fmt.Println("checked in")
+
+	case "poll":
+		if err := c.Poll(); err != nil {
+			return err
+		}
+		fmt.Println("polled")
+
+	case "config":
+		conf, err := c.Config()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(conf)
+		return err
+
+	case "health":
+		health, err := c.Health()
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(health)
+		}
+		printHealth(os.Stdout, health)
+
+	default:
+		return fmt.Errorf("unknown command %q, see clientctl -h", command)
+	}
+
+	return nil
+}
+
+// readJob reads a job from the file, - is stdin
+func readJob(path string) (api.FixedJob, error) {
+	var job api.FixedJob
+
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return job, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return job, json.NewDecoder(r).Decode(&job)
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printTasks(w io.Writer, snap scheduler.Snapshot) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATE\tSTART\tEND\tPRIORITY\tDETAILS")
+
+	rows := func(tasks []scheduler.TaskInfo, fallback scheduler.TaskState) {
+		for _, t := range tasks {
+			details := t.Reason
+			if t.State == scheduler.StateRunning {
+				details = fmt.Sprintf("running for %s", time.Duration(t.ElapsedSeconds*float64(time.Second)).Round(time.Second))
+			}
+			state := t.State
+			if len(state) == 0 {
+				state = fallback
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", t.ID, state, formatTime(t.StartTime), formatTime(t.EndTime), t.Priority, details)
+		}
+	}
+	rows(snap.Running, scheduler.StateRunning)
+	rows(snap.Queued, scheduler.StateQueued)
+	rows(snap.Recurring, "recurring")
+	tw.Flush()
+
+	if snap.Draining {
+		fmt.Fprintln(w, "draining until", formatTime(snap.DrainDeadline))
+	}
+	if !snap.TimeSynced {
+		fmt.Fprintln(w, "time critical jobs wait for the time sync")
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.DateTime)
+}
+
+func printVerdict(w io.Writer, v api.Verdict) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tCODE\tDETAILS")
+	for _, c := range v.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, c.Status, orDash(c.Code), c.Message)
+	}
+	tw.Flush()
+
+	if len(v.Preempts) != 0 {
+		fmt.Fprintln(w, "would preempt", v.Preempts)
+	}
+	if v.Runnable {
+		fmt.Fprintln(w, "runnable")
+	}
+}
+
+func printHealth(w io.Writer, h control.Health) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintf(tw, "sensor\t%s (client %s)\n", h.Sensor, h.Version)
+
+	if g := h.GNSS; g != nil {
+		fmt.Fprintf(tw, "gnss\tfix: %v, time valid: %v, lat: %f lon: %f alt: %.1fm\n", g.Fix, g.TimeValid, g.Lat, g.Lon, g.AltMSL)
+	} else {
+		fmt.Fprintln(tw, "gnss\tunavailable")
+	}
+
+	if n := h.Network; n != nil {
+		fmt.Fprintf(tw, "network\tconnectivity: %v, gsm: %s, wifi: %s, ethernet: %s\n", n.Connectivity, orDash(n.GSM), orDash(n.WiFi), orDash(n.Ethernet))
+	} else {
+		fmt.Fprintln(tw, "network\tunavailable")
+	}
+
+	if o := h.OTA; o != nil {
+		fmt.Fprintf(tw, "ota\t%s\n", o.Slots)
+	} else {
+		fmt.Fprintln(tw, "ota\tunavailable")
+	}
+
+	if u := h.USB; u != nil {
+		fmt.Fprintf(tw, "usb\thotplug: %v, devices: %v\n", u.Hotplug, u.Devices)
+	} else {
+		fmt.Fprintln(tw, "usb\tunavailable")
+	}
+
+	s := h.Scheduler
+	fmt.Fprintf(tw, "scheduler\tqueued: %d, running: %d, recurring: %d, time synced: %v, draining: %v, pushed: %v\n",
+		s.Queued, s.Running, s.Recurring, s.TimeSynced, s.Draining, s.Pushed)
+}
+
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+
+	return s
+}
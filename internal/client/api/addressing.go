@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/imroc/req/v3"
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+// Servers that accept job ids in the job updates announce it with "id" in this header of the job list and the job stream
+const JobAddressingHeader = "X-Job-Addressing"
+
+var (
+	ErrNoJobID   = errors.New("job has no id")
+	ErrNoJobName = errors.New("job has no name, but the server addresses jobs by name")
+)
+
+// JobsByID reports if the job updates address the jobs by id, names are only used for servers that expect them
+func (r *RestAPI) JobsByID() bool {
+	switch r.cm.C().JobAddressing {
+	case config.JobAddressingID:
+		return true
+	case config.JobAddressingName:
+		return false
+	}
+
+	return r.serverJobsByID.Load()
+}
+
+// negotiateAddressing takes over the addressing the server announced with the job list or the job stream
+func (r *RestAPI) negotiateAddressing(resp *req.Response) {
+	byID := strings.EqualFold(strings.TrimSpace(resp.Header.Get(JobAddressingHeader)), "id")
+	if r.serverJobsByID.Swap(byID) != byID {
+		log.Info("server changed the job addressing", zap.Bool("byID", byID))
+	}
+}
+
+// jobUpdateURL returns the url of the status update of the job
+func (r *RestAPI) jobUpdateURL(job FixedJob, status string) (string, error) {
+	sensorName := r.clientCM.C().SensorName
+
+	if r.JobsByID() {
+		if len(job.Id) == 0 {
+			return "", ErrNoJobID
+		}
+
+		return "fixedjobs/update/" + url.PathEscape(job.Id) + "?sensor_name=" + url.QueryEscape(sensorName) + "&status=" + url.QueryEscape(status), nil
+	}
+
+	if len(job.Name) == 0 {
+		return "", ErrNoJobName
+	}
+
+	return "fixedjobs/" + sensorName + "?job_name=" + url.QueryEscape(job.Name) + "&status=" + url.QueryEscape(status), nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// newServerAPI returns an api for the sensor "sensor" that talks to the server at url
+func newServerAPI(t *testing.T, url string) *RestAPI {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	assert.NoError(t, os.WriteFile(path, []byte("[client]\nsensor_name = 'sensor'\n[api]\nurl = '"+url+"'\n"), 0644))
+
+	conf := config.NewManager()
+	assert.NoError(t, conf.Load(path, false))
+
+	a, err := NewRestAPI(conf, false)
+	assert.NoError(t, err)
+	return a
+}
+
+func newTestAPI(t *testing.T) *RestAPI {
+	t.Helper()
+
+	a := newServerAPI(t, "http://server/")
+	httpmock.ActivateNonDefault(a.GetClient().GetClient())
+	t.Cleanup(httpmock.DeactivateAndReset)
+	return a
+}
+
+func TestJobAddressing(t *testing.T) {
+	log.Init(true)
+	a := newTestAPI(t)
+
+	announced := ""
+	httpmock.RegisterResponder("GET", "http://server/fixedjobs/sensor", func(req *http.Request) (*http.Response, error) {
+		resp := httpmock.NewStringResponse(200, `{"data": []}`)
+		resp.Header.Set("Content-Type", "application/json")
+		if len(announced) != 0 {
+			resp.Header.Set(JobAddressingHeader, announced)
+		}
+		return resp, nil
+	})
+
+	var updates []string
+	var bodies []string
+	httpmock.RegisterRegexpResponder("PUT", regexp.MustCompile(`^http://server/fixedjobs/`), func(req *http.Request) (*http.Response, error) {
+		updates = append(updates, req.URL.RequestURI())
+		body := []byte{}
+		if req.Body != nil {
+			body, _ = io.ReadAll(req.Body)
+		}
+		bodies = append(bodies, string(body))
+		return httpmock.NewStringResponse(200, ""), nil
+	})
+
+	job := FixedJob{Id: "42", Name: "capture 1"}
+
+	// Servers that did not announce ids get the name
+	_, err := a.GetJobs()
+	assert.NoError(t, err)
+	assert.False(t, a.JobsByID())
+	assert.NoError(t, a.PutJobUpdate(job, "running"))
+	assert.ErrorIs(t, a.PutJobUpdate(FixedJob{Id: "43"}, "running"), ErrNoJobName)
+
+	// Once announced, the id is used and the name is only displayed
+	announced = "id"
+	_, err = a.GetJobs()
+	assert.NoError(t, err)
+	assert.True(t, a.JobsByID())
+	assert.NoError(t, a.PutJobResult(job, "finished", JobResult{JobID: "42", Status: "finished"}))
+	assert.NoError(t, a.PutJobUpdate(FixedJob{Id: "43"}, "running"))
+	assert.ErrorIs(t, a.PutJobUpdate(FixedJob{Name: "capture"}, "running"), ErrNoJobID)
+
+	assert.Equal(t, []string{
+		"/fixedjobs/sensor?job_name=capture+1&status=running",
+		"/fixedjobs/update/42?sensor_name=sensor&status=finished",
+		"/fixedjobs/update/43?sensor_name=sensor&status=running",
+	}, updates)
+	assert.Empty(t, bodies[0])
+	assert.Contains(t, bodies[1], `"job_id":"42"`)
+
+	// The configuration overrides the server
+	a.cm.Set(func(c *config.ApiConfig) { c.JobAddressing = config.JobAddressingName })
+	assert.False(t, a.JobsByID())
+	a.cm.Set(func(c *config.ApiConfig) { c.JobAddressing = config.JobAddressingID })
+	announced = ""
+	_, err = a.GetJobs()
+	assert.NoError(t, err)
+	assert.True(t, a.JobsByID())
+}
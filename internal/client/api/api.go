@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -20,6 +21,7 @@ import (
 	"github.com/LeoCommon/client/internal/client/api/jwt"
 	"github.com/LeoCommon/client/internal/client/config"
 	"github.com/LeoCommon/client/pkg/log"
+	"github.com/LeoCommon/client/pkg/progress"
 
 	"github.com/imroc/req/v3"
 )
@@ -33,6 +35,13 @@ type RestAPI struct {
 	conf     *config.Manager
 	cm       *config.ApiConfigManager
 	clientCM *config.ClientConfigManager
+
+	// Set once the server announced that it accepts job ids
+	serverJobsByID atomic.Bool
+
+	// Optional, keeps the updates that could not be delivered
+	outbox *Outbox
+	online func() bool
 }
 
 func NewRestAPI(conf *config.Manager, debug bool) (*RestAPI, error) {
@@ -112,13 +121,10 @@ func (a *RestAPI) GetClient() *req.Client {
 	return a.client
 }
 
+// PutSensorUpdate checks in with the server, a failed check-in is queued in the outbox but still reported
 func (r *RestAPI) PutSensorUpdate(status SensorStatus) error {
-	resp, err := r.client.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(status).
-		Put("sensors/update/" + r.clientCM.C().SensorName)
-
-	return h.ErrorFromResponse(err, resp)
+	_, err := r.deliver(OutboxEntry{Kind: OutboxCheckin, Key: string(OutboxCheckin), Sensor: &status})
+	return err
 }
 
 func (r *RestAPI) GetJobs() ([]FixedJob, error) {
@@ -132,21 +138,151 @@ func (r *RestAPI) GetJobs() ([]FixedJob, error) {
 		return []FixedJob{}, err
 	}
 
+	if resp.IsSuccessState() {
+		r.negotiateAddressing(resp)
+	}
+
 	return respCont.Data, h.ErrorFromResponse(nil, resp)
 }
 
-func (r *RestAPI) PutJobUpdate(jobName string, status string) error {
-	//TODO: change job_name to jobID
-	if !(strings.HasPrefix(status, "running") ||
-		strings.HasPrefix(status, "finished") ||
-		strings.HasPrefix(status, "failed")) {
-		return errors.New("status has to start with 'running', 'finished' or 'failed'")
+var errJobStatus = errors.New("status has to start with 'running', 'finished', 'failed', 'interrupted', 'preempted', 'retrying', 'rejected', 'cancelled', 'dry_run' or 'preflight_failed'")
+
+// isJobStatus checks the status string the server expects
+func isJobStatus(status string) bool {
+	for _, prefix := range []string{"running", "finished", "failed", "interrupted", "preempted", "retrying", "rejected", "cancelled", "dry_run", "preflight_failed"} {
+		if strings.HasPrefix(status, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PutJobUpdate sends the status of a job
+func (r *RestAPI) PutJobUpdate(job FixedJob, status string) error {
+	if !isJobStatus(status) {
+		return errJobStatus
+	}
+
+	return r.putJobUpdate(job, status, nil)
+}
+
+// PutJobResult sends the final status of a job with the structured result as body
+// Servers that do not know the result document still get the status
+func (r *RestAPI) PutJobResult(job FixedJob, status string, result JobResult) error {
+	if !isJobStatus(status) {
+		return errJobStatus
+	}
+
+	return r.putJobUpdate(job, status, &result)
+}
+
+// JobSink receives the updates and files of jobs, the RestAPI for the jobs of the server
+type JobSink interface {
+	PutJobUpdate(job FixedJob, status string) error
+	PutJobResult(job FixedJob, status string, result JobResult) error
+	PostSensorData(ctx context.Context, jobID string, filePath string) error
+}
+
+// putJobUpdate sends the job update, if the server can not be reached it is queued in the outbox
+func (r *RestAPI) putJobUpdate(job FixedJob, status string, result *JobResult) error {
+	queued, err := r.deliver(OutboxEntry{
+		Kind:    OutboxJobUpdate,
+		Key:     jobUpdateKey(job, status),
+		JobID:   job.Id,
+		JobName: job.Name,
+		Status:  status,
+		Result:  result,
+	})
+	if queued {
+		return nil
+	}
+
+	return err
+}
+
+// EnableOutbox queues the updates that could not be delivered, they are replayed in order while online returns true
+func (r *RestAPI) EnableOutbox(outbox *Outbox, online func() bool) {
+	r.outbox = outbox
+	r.online = online
+}
+
+// FlushOutbox replays the queued updates, updates the server rejects are dropped
+func (r *RestAPI) FlushOutbox() error {
+	if r.outbox == nil || r.outbox.Len() == 0 {
+		return nil
+	}
+
+	if r.online != nil && !r.online() {
+		return ErrOffline
+	}
+
+	sent, err := r.outbox.Flush(func(e OutboxEntry) error {
+		err := r.send(e)
+		if err != nil && !undeliverable(err) {
+			log.Error("server rejected queued update, dropping it", zap.String("key", e.Key), zap.String("status", e.Status), zap.Error(err))
+			return nil
+		}
+
+		return err
+	})
+	if sent > 0 {
+		log.Info("replayed queued updates", zap.Int("sent", sent), zap.Int("left", r.outbox.Len()))
+	}
+
+	return err
+}
+
+// deliver sends the update behind the queued ones or queues it, err is the reason it was not sent
+func (r *RestAPI) deliver(e OutboxEntry) (queued bool, err error) {
+	if r.outbox == nil {
+		return false, r.send(e)
+	}
+
+	// Earlier updates go first
+	err = r.FlushOutbox()
+	if err == nil {
+		err = r.send(e)
+		if !undeliverable(err) {
+			return false, err
+		}
 	}
-	resp, err := r.client.R().
-		Put("fixedjobs/" + r.clientCM.C().SensorName + "?job_name=" + jobName + "&status=" + status)
 
-	//resp, err := r.client.R().Put("fixedjobs/update/" + jobID + "?sensor_name=" + r.clientCM.C().SensorName + "&status=" + status)
+	if qerr := r.outbox.Add(e); qerr != nil {
+		log.Error("could not queue update", zap.String("key", e.Key), zap.Error(qerr))
+		return false, errors.Join(err, qerr)
+	}
+
+	log.Warn("update queued in the outbox", zap.String("key", e.Key), zap.String("status", e.Status), zap.Error(err))
+	return true, err
+}
+
+// send performs the request of the update
+func (r *RestAPI) send(e OutboxEntry) error {
+	req := r.client.R()
+	if e.Seq != 0 {
+		req.SetHeader(IdempotencyKeyHeader, fmt.Sprintf("%s-%d", r.clientCM.C().SensorName, e.Seq))
+	}
+
+	if e.Kind == OutboxCheckin {
+		resp, err := req.
+			SetHeader("Content-Type", "application/json").
+			SetBody(e.Sensor).
+			Put("sensors/update/" + r.clientCM.C().SensorName)
+
+		return h.ErrorFromResponse(err, resp)
+	}
+
+	updateURL, err := r.jobUpdateURL(FixedJob{Id: e.JobID, Name: e.JobName}, e.Status)
+	if err != nil {
+		return err
+	}
+
+	if e.Result != nil {
+		req.SetBody(e.Result)
+	}
 
+	resp, err := req.Put(updateURL)
 	return h.ErrorFromResponse(err, resp)
 }
 
@@ -176,7 +312,16 @@ func (r *RestAPI) PostChunk(ctx context.Context, sensorName string, jobID string
 		SetFile("in_file", chunkFilePath).
 		EnableForceChunkedEncoding().
 		SetUploadCallbackWithInterval(func(info req.UploadInfo) {
-			log.Info("chunk "+fmt.Sprint(chunkNr)+"/"+fmt.Sprint(chunkNr+chunksRemaining)+" upload progress", zap.String("file", info.FileName), zap.Float64("pct", float64(info.UploadedSize)/float64(info.FileSize)*100.0))
+			pct := float64(info.UploadedSize) / float64(info.FileSize) * 100.0
+			log.Info("chunk "+fmt.Sprint(chunkNr)+"/"+fmt.Sprint(chunkNr+chunksRemaining)+" upload progress", zap.String("file", info.FileName), zap.Float64("pct", pct))
+
+			// Report the progress of the whole upload
+			chunks := chunkNr + chunksRemaining + 1
+			progress.Report(ctx, progress.Update{
+				Stage:   "upload",
+				Percent: (float64(chunkNr) + pct/100.0) / float64(chunks) * 100.0,
+				Message: fmt.Sprintf("chunk %d/%d", chunkNr+1, chunks),
+			})
 		}, 1*time.Second).
 		Post("data/upload/" + sensorName + "/" + jobID + "?chunk_nr=" + fmt.Sprint(chunkNr) + "&chunks_remaining=" + fmt.Sprint(chunksRemaining) + "&chunk_md5=" + chunkFileMD5)
 	if err != nil {
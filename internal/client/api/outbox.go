@@ -0,0 +1,240 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	h "github.com/LeoCommon/client/internal/client/api/helpers"
+	"github.com/LeoCommon/client/pkg/file"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+var ErrOffline = errors.New("no network connectivity")
+
+// The outbox is kept next to the job data so it survives reboots
+const OutboxFileName = "outbox.json"
+
+// Upper bound of queued updates, progress updates and check-ins are dropped first
+const MaxOutboxEntries = 500
+
+// Replayed updates carry the outbox sequence, so the server can detect duplicates
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type OutboxKind string
+
+const (
+	OutboxJobUpdate OutboxKind = "job_update"
+	OutboxCheckin   OutboxKind = "checkin"
+)
+
+// OutboxEntry is an update that could not be delivered yet
+type OutboxEntry struct {
+	Seq    uint64     `json:"seq"`
+	Kind   OutboxKind `json:"kind"`
+	Queued time.Time  `json:"queued"`
+	// A newer entry with the same key replaces the older one
+	Key string `json:"key"`
+
+	// Job updates
+	JobID   string     `json:"job_id,omitempty"`
+	JobName string     `json:"job_name,omitempty"`
+	Status  string     `json:"status,omitempty"`
+	Result  *JobResult `json:"result,omitempty"`
+
+	// Check-ins
+	Sensor *SensorStatus `json:"sensor,omitempty"`
+}
+
+// droppable entries only carry intermediate state that a later entry supersedes
+func (e *OutboxEntry) droppable() bool {
+	return e.Kind == OutboxCheckin || strings.HasPrefix(e.Status, "running")
+}
+
+// jobUpdateKey deduplicates the updates of a job by the kind of status, so only the latest progress is kept
+func jobUpdateKey(job FixedJob, status string) string {
+	kind, _, _ := strings.Cut(status, "(")
+	return "job:" + job.Id + "/" + job.Step + ":" + job.Name + ":" + kind
+}
+
+type outboxFile struct {
+	Seq     uint64        `json:"seq"`
+	Entries []OutboxEntry `json:"entries"`
+}
+
+// Outbox is a persistent, ordered queue of the updates the server did not get yet
+type Outbox struct {
+	m       sync.Mutex
+	path    string
+	max     int
+	seq     uint64
+	entries []OutboxEntry
+
+	// Only one replay at a time, so the order is kept
+	flushing sync.Mutex
+}
+
+// NewOutbox loads the outbox at path, a missing file is an empty outbox
+func NewOutbox(path string, max int) (*Outbox, error) {
+	o := &Outbox{path: path, max: max}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return o, err
+	}
+
+	var f outboxFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return o, err
+	}
+	o.seq, o.entries = f.Seq, f.Entries
+
+	return o, nil
+}
+
+// Len returns the number of queued updates
+func (o *Outbox) Len() int {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	return len(o.entries)
+}
+
+// Entries returns a copy of the queued updates in delivery order
+func (o *Outbox) Entries() []OutboxEntry {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	return append([]OutboxEntry{}, o.entries...)
+}
+
+// Add queues the update behind all others, it replaces a queued update with the same key
+func (o *Outbox) Add(e OutboxEntry) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	o.seq++
+	e.Seq = o.seq
+	if e.Queued.IsZero() {
+		e.Queued = time.Now()
+	}
+
+	for i := range o.entries {
+		if o.entries[i].Key == e.Key {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			break
+		}
+	}
+	o.entries = append(o.entries, e)
+
+	for len(o.entries) > max(o.max, 1) {
+		o.dropOne()
+	}
+
+	return o.persist()
+}
+
+// dropOne removes the oldest droppable entry or the oldest one, must be called with the lock held
+func (o *Outbox) dropOne() {
+	idx := 0
+	for i := range o.entries {
+		if o.entries[i].droppable() {
+			idx = i
+			break
+		}
+	}
+
+	log.Warn("outbox full, dropping update", zap.String("key", o.entries[idx].Key), zap.String("status", o.entries[idx].Status))
+	o.entries = append(o.entries[:idx], o.entries[idx+1:]...)
+}
+
+// Flush sends the queued updates in order and stops at the first failure, it returns the number of sent updates
+func (o *Outbox) Flush(send func(OutboxEntry) error) (int, error) {
+	o.flushing.Lock()
+	defer o.flushing.Unlock()
+
+	sent := 0
+	for {
+		o.m.Lock()
+		if len(o.entries) == 0 {
+			o.m.Unlock()
+			return sent, nil
+		}
+		e := o.entries[0]
+		o.m.Unlock()
+
+		if err := send(e); err != nil {
+			return sent, err
+		}
+		sent++
+
+		// The entry might have been replaced in the meantime
+		o.m.Lock()
+		for i := range o.entries {
+			if o.entries[i].Seq == e.Seq {
+				o.entries = append(o.entries[:i], o.entries[i+1:]...)
+				break
+			}
+		}
+		err := o.persist()
+		o.m.Unlock()
+
+		if err != nil {
+			return sent, err
+		}
+	}
+}
+
+// persist replaces the outbox file atomically, must be called with the lock held
+func (o *Outbox) persist() error {
+	data, err := json.Marshal(outboxFile{Seq: o.seq, Entries: o.entries})
+	if err != nil {
+		return err
+	}
+
+	tmpPath := o.path + ".tmp"
+	f, err := file.CreateFileP(tmpPath, 0750)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Clean(o.path))
+}
+
+// undeliverable reports if the update should be queued, the server rejecting it will not change by retrying
+func undeliverable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var respErr *h.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.Code >= http.StatusInternalServerError ||
+			respErr.Code == http.StatusRequestTimeout ||
+			respErr.Code == http.StatusTooManyRequests
+	}
+
+	// Transport errors, the uplink is gone
+	return !errors.Is(err, ErrNoJobID) && !errors.Is(err, ErrNoJobName)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox(t *testing.T) {
+	log.Init(true)
+	path := filepath.Join(t.TempDir(), OutboxFileName)
+	o, err := NewOutbox(path, 3)
+	assert.NoError(t, err)
+
+	job := FixedJob{Id: "42", Name: "capture"}
+	add := func(status string) {
+		assert.NoError(t, o.Add(OutboxEntry{Kind: OutboxJobUpdate, Key: jobUpdateKey(job, status), JobID: job.Id, Status: status}))
+	}
+	statuses := func() []string {
+		s := []string{}
+		for _, e := range o.Entries() {
+			s = append(s, e.Status)
+		}
+		return s
+	}
+
+	// Only the latest progress is kept, behind the other updates
+	add("running")
+	add("running(capture:10%)")
+	add("retrying(1/3:busy)")
+	add("running(capture:50%)")
+	assert.Equal(t, []string{"retrying(1/3:busy)", "running(capture:50%)"}, statuses())
+
+	// Progress is dropped first once the outbox is full
+	assert.NoError(t, o.Add(OutboxEntry{Kind: OutboxCheckin, Key: string(OutboxCheckin), Sensor: &SensorStatus{StatusTime: 1}}))
+	add("finished")
+	assert.Equal(t, []string{"retrying(1/3:busy)", "", "finished"}, statuses())
+
+	// The outbox survives restarts
+	o, err = NewOutbox(path, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"retrying(1/3:busy)", "", "finished"}, statuses())
+
+	// Replays stop at the first failure and keep the order
+	var sent []string
+	n, err := o.Flush(func(e OutboxEntry) error {
+		if e.Kind == OutboxCheckin {
+			return errors.New("uplink gone")
+		}
+		sent = append(sent, e.Status)
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"retrying(1/3:busy)"}, sent)
+
+	n, err = o.Flush(func(e OutboxEntry) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Zero(t, o.Len())
+
+	// Sequence numbers keep increasing across restarts
+	add("running")
+	seq := o.Entries()[0].Seq
+	o, err = NewOutbox(path, 3)
+	assert.NoError(t, err)
+	add("finished")
+	assert.Greater(t, o.Entries()[1].Seq, seq)
+}
+
+func TestOutboxDelivery(t *testing.T) {
+	log.Init(true)
+	a := newTestAPI(t)
+	outbox, err := NewOutbox(filepath.Join(t.TempDir(), OutboxFileName), MaxOutboxEntries)
+	assert.NoError(t, err)
+	online := false
+	a.EnableOutbox(outbox, func() bool { return online })
+
+	down := true
+	var updates []string
+	var keys []string
+	httpmock.RegisterRegexpResponder("PUT", regexp.MustCompile(`^http://server/`), func(req *http.Request) (*http.Response, error) {
+		if down {
+			return httpmock.NewStringResponse(http.StatusBadGateway, ""), nil
+		}
+		updates = append(updates, req.URL.RequestURI())
+		keys = append(keys, req.Header.Get(IdempotencyKeyHeader))
+		return httpmock.NewStringResponse(200, ""), nil
+	})
+
+	job := FixedJob{Id: "42", Name: "capture"}
+
+	// Job updates are queued, the job does not notice, failed check-ins are still reported
+	assert.NoError(t, a.PutJobUpdate(job, "running"))
+	assert.NoError(t, a.PutJobResult(job, "finished", JobResult{JobID: "42", Status: "finished"}))
+	assert.Error(t, a.PutSensorUpdate(SensorStatus{StatusTime: 1}))
+	assert.Equal(t, 3, outbox.Len())
+
+	// Nothing is replayed without connectivity
+	down = false
+	assert.ErrorIs(t, a.FlushOutbox(), ErrOffline)
+	assert.Empty(t, updates)
+
+	// Once back online the queue is replayed in order before the new check-in
+	online = true
+	assert.NoError(t, a.PutSensorUpdate(SensorStatus{StatusTime: 2}))
+	assert.Zero(t, outbox.Len())
+	assert.Equal(t, []string{
+		"/fixedjobs/sensor?job_name=capture&status=running",
+		"/fixedjobs/sensor?job_name=capture&status=finished",
+		"/sensors/update/sensor",
+		"/sensors/update/sensor",
+	}, updates)
+	assert.Equal(t, []string{"sensor-1", "sensor-2", "sensor-3", ""}, keys)
+
+	// Updates the server rejects are not queued
+	httpmock.RegisterRegexpResponder("PUT", regexp.MustCompile(`^http://server/`), httpmock.NewStringResponder(http.StatusBadRequest, ""))
+	assert.Error(t, a.PutJobUpdate(job, "running"))
+	assert.Zero(t, outbox.Len())
+}
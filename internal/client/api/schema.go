@@ -2,13 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 )
 
 // Some structs to handle the Json, coming from the server
 type FixedJob struct {
-	StartTime time.Time
-	EndTime   time.Time
+	StartTime time.Time         `json:"-"`
+	EndTime   time.Time         `json:"-"`
 	Arguments map[string]string `json:"arguments"`
 	States    map[string]string `json:"states"`
 	Id        string            `json:"id"`
@@ -16,6 +17,64 @@ type FixedJob struct {
 	Command   string            `json:"command"`
 	Status    string            `json:"status"`
 	Sensors   []string          `json:"sensors"`
+	// Jobs with a higher priority preempt overlapping jobs with a lower one
+	Priority int `json:"priority,omitempty"`
+	// Optional, overrides the retry settings of the client
+	Retry *RetryOptions `json:"retry,omitempty"`
+	// Optional, turns the job into a recurring job
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+	// Optional, turns the job into a composite job whose steps run as a chain within the job window
+	Steps []JobStep `json:"steps,omitempty"`
+	// Set on the jobs expanded from a composite job, the name of the step they execute
+	Step string `json:"step,omitempty"`
+	// Optional, the signed form of the job
+	Signature *JobSignature `json:"signature,omitempty"`
+	// Only check if the job could run, the result carries the verdict
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// JobSignature carries a job signed by the server, the signed document is what gets executed
+type JobSignature struct {
+	// Base64 of the signed JSON document, the job along with the sensors it is bound to and its expiry
+	Payload string `json:"payload"`
+	// Base64 of the Ed25519 signature of the decoded payload
+	Signature string `json:"signature"`
+}
+
+// JobStep is a single command of a composite job
+type JobStep struct {
+	Name      string            `json:"name"`
+	Command   string            `json:"command"`
+	Arguments map[string]string `json:"arguments"`
+	// Steps that have to succeed before this one runs
+	After []string `json:"after,omitempty"`
+	// Steps that have to end before this one runs, whatever their outcome
+	AfterAny []string `json:"after_any,omitempty"`
+}
+
+// RetryOptions define how often a failed job is retried within its window
+type RetryOptions struct {
+	MaxAttempts    int   `json:"max_attempts"`
+	BackoffSeconds int64 `json:"backoff_s,omitempty"`
+}
+
+// Recurrence describes how often a recurring job is repeated, either by interval or by cron expression (UTC)
+// The job StartTime is the first possible occurrence
+type Recurrence struct {
+	Cron            string `json:"cron,omitempty"`
+	IntervalSeconds int64  `json:"interval_s,omitempty"`
+	DurationSeconds int64  `json:"duration_s"`
+	// Optional unix timestamp after which no occurrence starts
+	Until int64 `json:"until,omitempty"`
+}
+
+// UntilTime returns the end date or the zero time if the job recurs forever
+func (r *Recurrence) UntilTime() time.Time {
+	if r.Until == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(r.Until, 0).UTC()
 }
 
 // Custom unmarshaller so we can use time.Time within the go code and avoid time mistakes
@@ -36,6 +95,45 @@ func (j *FixedJob) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Custom marshaller that writes the same unix timestamps the server sends
+func (j FixedJob) MarshalJSON() ([]byte, error) {
+	type Alias FixedJob
+	return json.Marshal(&struct {
+		Alias
+		StartTime int64 `json:"start_time"`
+		EndTime   int64 `json:"end_time"`
+	}{
+		Alias:     Alias(j),
+		StartTime: j.StartTime.Unix(),
+		EndTime:   j.EndTime.Unix(),
+	})
+}
+
+// StepStatus adds the step name to a job status if the job was expanded from a composite job
+// e.g. "running(capture)" or "failed(capture:reason)"
+func (j *FixedJob) StepStatus(status string) string {
+	if len(j.Step) == 0 {
+		return status
+	}
+
+	if idx := strings.Index(status, "("); idx >= 0 {
+		return status[:idx+1] + j.Step + ":" + status[idx+1:]
+	}
+
+	return status + "(" + j.Step + ")"
+}
+
+// FileID identifies the job and the step it executes in file and directory names, e.g. "42" or "42_capture"
+func (j *FixedJob) FileID() string {
+	id := j.Id
+	if len(j.Step) != 0 {
+		id += "_" + j.Step
+	}
+
+	// Never leave the job directories
+	return strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(id)
+}
+
 func (j *FixedJob) Json() string {
 	js, _ := json.Marshal(j)
 	return string(js)
@@ -57,3 +155,49 @@ type SensorStatus struct {
 	LocationLon        float64 `json:"location_lon"`
 	TemperatureCelsius float64 `json:"temperature_celsius"`
 }
+
+// JobResult is the outcome of a job, sent along with the status of its final update
+type JobResult struct {
+	JobID string `json:"job_id"`
+	Step  string `json:"step,omitempty"`
+	// finished, failed, retrying, preempted, rejected, interrupted, cancelled or dry_run
+	Status string `json:"status"`
+	// Stable error code, empty if the job finished
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Unix timestamps in milliseconds, unset if unknown e.g. for jobs that never ran
+	ScheduledAt int64 `json:"scheduled_at_ms,omitempty"`
+	StartedAt   int64 `json:"started_at_ms,omitempty"`
+	FinishedAt  int64 `json:"finished_at_ms"`
+	// The attempt starting at 1 and the maximum number of attempts
+	Attempt     int                `json:"attempt,omitempty"`
+	MaxAttempts int                `json:"max_attempts,omitempty"`
+	Artifacts   []Artifact         `json:"artifacts,omitempty"`
+	Metrics     map[string]float64 `json:"metrics,omitempty"`
+	// Set for dry runs
+	Verdict *Verdict `json:"verdict,omitempty"`
+}
+
+// Verdict tells if a job could run on the sensor, see FixedJob.DryRun
+type Verdict struct {
+	Runnable bool          `json:"runnable"`
+	Checks   []CheckResult `json:"checks"`
+	// Tasks that would be preempted by the job
+	Preempts []string `json:"preempts,omitempty"`
+}
+
+// CheckResult is the outcome of a single check of a dry run or pre-flight
+type CheckResult struct {
+	Name string `json:"name"`
+	// passed, failed or skipped
+	Status  string `json:"status"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Artifact is a file a job produced
+type Artifact struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
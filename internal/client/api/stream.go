@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	h "github.com/LeoCommon/client/internal/client/api/helpers"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+// Servers with push support stream the job events of a sensor from this endpoint as server-sent events
+const JobStreamPath = "fixedjobs/stream/"
+
+// The server has to send something, at least a keepalive comment, within this time or the stream is considered dead
+const StreamIdleTimeout = 90 * time.Second
+
+// Upper bound of a single event, e.g. the complete job list
+const maxEventSize = 4 * 1024 * 1024
+
+var (
+	ErrStreamUnsupported = errors.New("server does not support the job event stream")
+	ErrStreamIdle        = errors.New("job event stream was idle for too long")
+)
+
+type JobEventType string
+
+const (
+	// The complete job list of the sensor, sent after connecting
+	JobEventList JobEventType = "jobs"
+	// A new or changed job
+	JobEventJob JobEventType = "job"
+	// A job was cancelled or deleted
+	JobEventCancel JobEventType = "cancel"
+	// Configuration changes, the same settings as the set_sys_config job
+	JobEventConfig JobEventType = "config"
+)
+
+// JobEvent is an event of the job stream, only the fields of its type are set
+type JobEvent struct {
+	// Sent back as Last-Event-ID when reconnecting
+	ID   string
+	Type JobEventType
+
+	Jobs   []FixedJob
+	Job    *FixedJob
+	JobID  string
+	Config map[string]string
+}
+
+// sseEvent is a raw server-sent event
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// readEvents parses the server-sent events of r, see https://html.spec.whatwg.org/multipage/server-sent-events.html
+// Every line is passed to seen, comments are only used as keepalive. The id of an event is kept for the following ones
+func readEvents(r io.Reader, seen func(), fn func(sseEvent)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+
+	var e sseEvent
+	var data []string
+	for scanner.Scan() {
+		seen()
+
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) == 0 {
+			// Dispatch, events without data are ignored
+			if len(data) != 0 {
+				e.data = strings.Join(data, "\n")
+				fn(e)
+			}
+			e.event, data = "", nil
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			e.id = value
+		case "event":
+			e.event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return io.ErrUnexpectedEOF
+}
+
+// decodeEvent turns a raw event into a job event
+func decodeEvent(raw sseEvent) (JobEvent, error) {
+	e := JobEvent{ID: raw.id, Type: JobEventType(raw.event)}
+
+	var err error
+	switch e.Type {
+	case JobEventList:
+		err = json.Unmarshal([]byte(raw.data), &e.Jobs)
+	case JobEventJob:
+		e.Job = &FixedJob{}
+		err = json.Unmarshal([]byte(raw.data), e.Job)
+	case JobEventCancel:
+		var cancel struct {
+			ID string `json:"id"`
+		}
+		err = json.Unmarshal([]byte(raw.data), &cancel)
+		e.JobID = cancel.ID
+		if err == nil && len(e.JobID) == 0 {
+			err = ErrNoJobID
+		}
+	case JobEventConfig:
+		err = json.Unmarshal([]byte(raw.data), &e.Config)
+	default:
+		err = errors.New("unknown event type " + raw.event)
+	}
+
+	return e, err
+}
+
+// StreamJobs connects to the job event stream and passes the events to fn until ctx is done or the stream breaks,
+// it always returns an error. lastEventID resumes the stream after the last event the client got
+func (r *RestAPI) StreamJobs(ctx context.Context, lastEventID string, fn func(JobEvent)) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// The stream is long-lived, the common request timeout would end it
+	client := r.client.Clone().SetTimeout(0)
+	request := client.R().
+		SetContext(ctx).
+		DisableAutoReadResponse().
+		SetHeader("Accept", "text/event-stream").
+		SetHeader("Cache-Control", "no-cache")
+	if len(lastEventID) != 0 {
+		request.SetHeader("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := request.Get(JobStreamPath + r.clientCM.C().SensorName)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return ErrStreamUnsupported
+	}
+	if !resp.IsSuccessState() {
+		return h.ErrorFromResponse(nil, resp)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return ErrStreamUnsupported
+	}
+
+	// Tick skips the polls while the stream is up, so the stream announces the addressing as well
+	r.negotiateAddressing(resp)
+
+	// A silently dropped connection would block the read forever
+	idle := time.AfterFunc(StreamIdleTimeout, func() { cancel(ErrStreamIdle) })
+	defer idle.Stop()
+
+	err = readEvents(resp.Body, func() { idle.Reset(StreamIdleTimeout) }, func(raw sseEvent) {
+		e, err := decodeEvent(raw)
+		if err != nil {
+			log.Error("ignoring invalid job event", zap.String("id", raw.id), zap.String("event", raw.event), zap.Error(err))
+			return
+		}
+
+		fn(e)
+	})
+
+	if cause := context.Cause(ctx); cause != nil {
+		return cause
+	}
+
+	return err
+}
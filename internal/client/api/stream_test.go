@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadEvents(t *testing.T) {
+	stream := ": keepalive\n\nid: 1\nevent: job\ndata: {\"id\":\r\ndata: \"42\"}\n\nevent: cancel\ndata:{\"id\":\"42\"}\n\nevent: empty\n\n"
+
+	lines := 0
+	var events []sseEvent
+	err := readEvents(strings.NewReader(stream), func() { lines++ }, func(e sseEvent) { events = append(events, e) })
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, 12, lines)
+	assert.Equal(t, []sseEvent{
+		{id: "1", event: "job", data: "{\"id\":\n\"42\"}"},
+		// The id is kept until the server sends a new one
+		{id: "1", event: "cancel", data: "{\"id\":\"42\"}"},
+	}, events)
+}
+
+func TestStreamJobs(t *testing.T) {
+	log.Init(true)
+
+	lastIDs := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/"+JobStreamPath+"sensor", r.URL.Path)
+		lastIDs <- r.Header.Get("Last-Event-ID")
+
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Header().Set(JobAddressingHeader, "id")
+		fmt.Fprint(w, "id: 7\nevent: jobs\ndata: [{\"id\":\"41\",\"start_time\":1700000000},{\"id\":\"42\"}]\n\n")
+		fmt.Fprint(w, "event: job\ndata: {\"id\":\"43\",\"command\":\"get_status\"}\n\n")
+		fmt.Fprint(w, "event: cancel\ndata: {\"id\":\"41\"}\n\n")
+		fmt.Fprint(w, "event: config\ndata: {\"polling_interval\":\"30s\"}\n\n")
+		fmt.Fprint(w, "event: unknown\ndata: {}\n\n")
+		fmt.Fprint(w, "event: cancel\ndata: {}\n\n")
+	}))
+	defer server.Close()
+
+	a := newServerAPI(t, server.URL+"/")
+	assert.False(t, a.JobsByID())
+	var events []JobEvent
+	err := a.StreamJobs(context.Background(), "6", func(e JobEvent) { events = append(events, e) })
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, "6", <-lastIDs)
+
+	// The job list is not polled while the stream is up, the stream announces the addressing
+	assert.True(t, a.JobsByID())
+
+	// Invalid events are skipped
+	assert.Len(t, events, 4)
+	assert.Equal(t, JobEventList, events[0].Type)
+	assert.Equal(t, "7", events[0].ID)
+	assert.Len(t, events[0].Jobs, 2)
+	assert.Equal(t, int64(1700000000), events[0].Jobs[0].StartTime.Unix())
+	assert.Equal(t, JobEventJob, events[1].Type)
+	assert.Equal(t, "get_status", events[1].Job.Command)
+	assert.Equal(t, JobEventCancel, events[2].Type)
+	assert.Equal(t, "41", events[2].JobID)
+	assert.Equal(t, JobEventConfig, events[3].Type)
+	assert.Equal(t, map[string]string{"polling_interval": "30s"}, events[3].Config)
+}
+
+func TestStreamJobsUnsupported(t *testing.T) {
+	log.Init(true)
+
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, "{}")
+	}))
+	defer server.Close()
+	a := newServerAPI(t, server.URL+"/")
+
+	// Servers without the endpoint, or that answer with something else
+	assert.ErrorIs(t, a.StreamJobs(context.Background(), "", func(JobEvent) {}), ErrStreamUnsupported)
+	status = http.StatusOK
+	assert.ErrorIs(t, a.StreamJobs(context.Background(), "", func(JobEvent) {}), ErrStreamUnsupported)
+
+	// Server errors are temporary
+	status = http.StatusServiceUnavailable
+	err := a.StreamJobs(context.Background(), "", func(JobEvent) {})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrStreamUnsupported)
+}
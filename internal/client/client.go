@@ -8,6 +8,7 @@ import (
 
 	"github.com/LeoCommon/client/internal/client/api"
 	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
 	"github.com/LeoCommon/client/pkg/log"
 	"github.com/LeoCommon/client/pkg/system/sensors"
 	"github.com/LeoCommon/client/pkg/system/services/gnss"
@@ -39,6 +40,9 @@ type App struct {
 	NetworkService net.NetworkService
 	UsbManager     *usb.USBDeviceManager
 	TestRunning    bool
+
+	// The task scheduler, set up by the job handler
+	Scheduler *scheduler.Scheduler
 }
 
 func (a *App) Shutdown() {
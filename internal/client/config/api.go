@@ -1,9 +1,12 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"errors"
 	"net/url"
 	"slices"
+	"strings"
 
 	jwtmisc "github.com/LeoCommon/client/internal/client/api/jwt/misc"
 )
@@ -25,6 +28,43 @@ func (b BearerTokenSource) SupportedOptions() []BearerTokenSource {
 	}
 }
 
+// How job updates identify the job on the server
+type JobAddressing string
+
+const (
+	// Use job ids once the server announces support for them, names before
+	JobAddressingAuto JobAddressing = "auto"
+	JobAddressingID   JobAddressing = "id"
+	JobAddressingName JobAddressing = "name"
+)
+
+// SupportedOptions lists the options for the config parser
+func (j JobAddressing) SupportedOptions() []JobAddressing {
+	return []JobAddressing{
+		JobAddressingAuto,
+		JobAddressingID,
+		JobAddressingName,
+	}
+}
+
+// How the server delivers jobs besides polling
+type JobPush string
+
+const (
+	// Jobs are only polled
+	JobPushOff JobPush = "off"
+	// Jobs are pushed over a server-sent event stream, polled while it is unavailable
+	JobPushSSE JobPush = "sse"
+)
+
+// SupportedOptions lists the options for the config parser
+func (j JobPush) SupportedOptions() []JobPush {
+	return []JobPush{
+		JobPushOff,
+		JobPushSSE,
+	}
+}
+
 type BearerCookieSettings struct {
 	RefreshTokenName string `toml:"refresh_name,omitempty" comment:"name of the refresh token cookie sent from the server"`
 	AccessTokenName  string `toml:"access_name,omitempty" comment:"name of the access token cookie sent from the server"`
@@ -64,6 +104,64 @@ type AuthSettings struct {
 	Bearer *AuthBearerSettings `toml:"bearer,omitempty" comment:"Bearer authentication settings"`
 }
 
+// Commands that change the system, they need a signature by default once a job signing key is pinned
+var DefaultSignedCommands = []string{
+	"reboot",
+	"reset",
+	"set_sys_config",
+	"set_network_conn",
+	"set_wifi_config",
+	"set_eth_config",
+	"set_gsm_config",
+}
+
+// Matches every command in JobSigningSettings.Require
+const AllCommands = "*"
+
+type JobSigningSettings struct {
+	PublicKeys []string `toml:"public_keys,omitempty" comment:"base64 Ed25519 public keys the server signs jobs with, signatures are not checked without one"`
+	Require    []string `toml:"require,omitempty" comment:"commands that are rejected unless signed, '*' for all, defaults to the commands that change the system"`
+}
+
+// Enabled returns true once a key is pinned
+func (s JobSigningSettings) Enabled() bool {
+	return len(s.PublicKeys) != 0
+}
+
+// Keys decodes the pinned public keys
+func (s JobSigningSettings) Keys() ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(s.PublicKeys))
+	for _, encoded := range s.PublicKeys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("job signing key is not an Ed25519 public key")
+		}
+
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+
+	return keys, nil
+}
+
+// Required returns true if jobs with this command have to be signed
+func (s JobSigningSettings) Required(command string) bool {
+	if !s.Enabled() {
+		return false
+	}
+
+	require := s.Require
+	if len(require) == 0 {
+		require = DefaultSignedCommands
+	}
+
+	// Matched like the job registry looks the commands up
+	command = strings.TrimSpace(command)
+	return slices.ContainsFunc(require, func(c string) bool { return c == AllCommands || strings.EqualFold(c, command) })
+}
+
 // Config contains the api configuration options
 type ApiConfig struct {
 	Auth                AuthSettings `toml:"auth"`
@@ -71,6 +169,12 @@ type ApiConfig struct {
 	Url                 string       `toml:"url"`
 	AllowInsecure       bool         `toml:"allow_insecure,omitempty"`
 	UploadChunksizeByte int          `toml:"upload_chunksize_byte"`
+	// Empty means JobAddressingAuto
+	JobAddressing JobAddressing `toml:"job_addressing,omitempty" comment:"auto, id or name, auto uses ids once the server supports them"`
+	// Empty means JobPushOff
+	Push JobPush `toml:"push,omitempty" comment:"off or sse, sse keeps an event stream open for job pushes and polls while it is down"`
+	// Jobs are only trusted as far as the TLS channel unless keys are pinned
+	Signing JobSigningSettings `toml:"signing,omitempty" comment:"end-to-end signatures of the jobs"`
 }
 
 type ApiConfigManager struct {
@@ -84,6 +188,21 @@ func (a *ApiConfigManager) Verify() error {
 		return err
 	}
 
+	// Verify the job addressing mode
+	if len(a.conf.JobAddressing) != 0 && !slices.Contains(a.conf.JobAddressing.SupportedOptions(), a.conf.JobAddressing) {
+		return errors.New("unsupported job addressing mode " + string(a.conf.JobAddressing))
+	}
+
+	// Verify the job push mode
+	if len(a.conf.Push) != 0 && !slices.Contains(a.conf.Push.SupportedOptions(), a.conf.Push) {
+		return errors.New("unsupported job push mode " + string(a.conf.Push))
+	}
+
+	// Verify the pinned job signing keys
+	if _, err := a.conf.Signing.Keys(); err != nil {
+		return err
+	}
+
 	// Verify that auth basic contains a password
 	if a.conf.Auth.Basic != nil && a.conf.Auth.Basic.Password == "" {
 		return errors.New("empty password for auth basic")
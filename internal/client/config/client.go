@@ -4,6 +4,18 @@ package config
 type ClientConfig struct {
 	SensorName string `toml:"sensor_name,omitempty"`
 	Debug      bool   `toml:"debug"`
+	// Empty means DefaultControlSocket
+	ControlSocket string `toml:"control_socket,omitempty" comment:"path of the unix socket clientctl connects to"`
+	ControlGroup  string `toml:"control_group,omitempty" comment:"members of this group may use the control socket besides the client user and root"`
+}
+
+// ControlSocketPath returns the path of the control socket
+func (c ClientConfig) ControlSocketPath() string {
+	if len(c.ControlSocket) == 0 {
+		return DefaultControlSocket
+	}
+
+	return c.ControlSocket
 }
 
 type ClientConfigManager struct {
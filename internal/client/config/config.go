@@ -28,6 +28,18 @@ const (
 	DefaultJobStorageDir   = UserdataDirectoryPrefix + "jobs/"
 	DefaultJobTmpDir       = DefaultTmpDir + "jobs/"
 	DefaultPollingInterval = time.Second * 60
+	DefaultRetryAttempts   = 3
+	DefaultRetryBackoff    = time.Second * 30
+	// Has to stay below the stop timeout of the systemd service
+	DefaultDrainTimeout = time.Second * 60
+	DefaultSpoolDir     = UserdataDirectoryPrefix + "spool/"
+	// USB drives are mounted below this directory while they are attached
+	DefaultMediaDir       = "/run/" + ProductName + "/media/"
+	DefaultMinFreeSpaceMB = 100
+	DefaultMaxTemperature = 80.0
+	DefaultPreflightLead  = time.Minute * 2
+	// clientctl talks to the running client over this socket
+	DefaultControlSocket = "/run/" + ProductName + "/control.sock"
 
 	DefaultDebugModeValue      = false
 	DefaultUploadChunksizeByte = 1000000 // 1MB
@@ -180,6 +192,45 @@ func (m *Manager) Save() error {
 	return nil
 }
 
+// Effective returns the configuration in use as TOML, credentials are masked
+func (m *Manager) Effective() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, value := range m.store {
+		value.lock()
+	}
+	defer func() {
+		for _, value := range m.store {
+			value.unlock()
+		}
+	}()
+
+	conf := *m.config
+	if basic := conf.Api.Auth.Basic; basic != nil {
+		masked := *basic
+		masked.Password = maskSecret(masked.Password)
+		conf.Api.Auth.Basic = &masked
+	}
+	if bearer := conf.Api.Auth.Bearer; bearer != nil {
+		masked := *bearer
+		masked.Refresh = maskSecret(masked.Refresh)
+		masked.Access = maskSecret(masked.Access)
+		conf.Api.Auth.Bearer = &masked
+	}
+
+	return toml.Marshal(conf)
+}
+
+// maskSecret hides a credential but shows if it is set
+func maskSecret(secret string) string {
+	if len(secret) == 0 {
+		return ""
+	}
+
+	return "********"
+}
+
 func New() *MainConfig {
 	return &MainConfig{}
 }
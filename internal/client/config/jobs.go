@@ -1,10 +1,99 @@
 package config
 
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// The scheduler resources whose capacity can be configured, capacities of other names would limit nothing
+var ResourceNames = []string{"SDRDevice", "CPUShare", "DiskWriteBandwidth"}
+
 // These are basic settings for every job
 type BaseJobSettings struct {
 	Disabled bool `toml:"disabled,omitempty"`
 }
 
+// Retry settings for failed jobs, the retries have to start within the job window
+type RetrySettings struct {
+	MaxAttempts int          `toml:"max_attempts,omitempty"`
+	Backoff     TOMLDuration `toml:"backoff,omitempty"`
+}
+
+func (r RetrySettings) Attempts() int {
+	if r.MaxAttempts == 0 {
+		return DefaultRetryAttempts
+	}
+
+	return r.MaxAttempts
+}
+
+func (r RetrySettings) BackoffDuration() time.Duration {
+	if r.Backoff == 0 {
+		return DefaultRetryBackoff
+	}
+
+	return time.Duration(r.Backoff)
+}
+
+func (j JobsConfig) DrainDuration() time.Duration {
+	if j.DrainTimeout == 0 {
+		return DefaultDrainTimeout
+	}
+
+	return time.Duration(j.DrainTimeout)
+}
+
+// Job files from a local directory or USB drives, for sensors without uplink
+type SpoolSettings struct {
+	Enabled bool `toml:"enabled,omitempty"`
+	// Defaults to DefaultSpoolDir
+	Dir string `toml:"dir,omitempty" comment:"directory with the jobs and outbox folders"`
+	// Also pick up jobs from the spool folder of USB drives
+	Removable bool `toml:"removable,omitempty" comment:"pick up jobs from the leocommon folder of USB drives, mounting them needs CAP_SYS_ADMIN"`
+}
+
+func (s SpoolSettings) Directory() string {
+	if s.Dir == "" {
+		return DefaultSpoolDir
+	}
+
+	return s.Dir
+}
+
+// Limits the pre-flight checks hold the jobs against
+type PreflightSettings struct {
+	MinFreeSpaceMB int64   `toml:"min_free_space_mb,omitempty" comment:"free space the storage path needs for any job"`
+	MaxTemperature float64 `toml:"max_temperature,omitempty" comment:"jobs do not start above this temperature in °C"`
+	// How long before the start the checks run first, they run again at the start
+	LeadTime TOMLDuration `toml:"lead_time,omitempty" comment:"how long before the start of a job its checks run first"`
+}
+
+// MinFreeSpace returns the free space in bytes
+func (p PreflightSettings) MinFreeSpace() uint64 {
+	if p.MinFreeSpaceMB <= 0 {
+		return DefaultMinFreeSpaceMB * 1000 * 1000
+	}
+
+	return uint64(p.MinFreeSpaceMB) * 1000 * 1000
+}
+
+func (p PreflightSettings) Lead() time.Duration {
+	if p.LeadTime == 0 {
+		return DefaultPreflightLead
+	}
+
+	return time.Duration(p.LeadTime)
+}
+
+func (p PreflightSettings) TemperatureLimit() float64 {
+	if p.MaxTemperature == 0 {
+		return DefaultMaxTemperature
+	}
+
+	return p.MaxTemperature
+}
+
 type StoragePath string
 
 func (j StoragePath) String() string {
@@ -31,6 +120,17 @@ type JobsConfig struct {
 	PollingInterval TOMLDuration    `toml:"polling_interval,omitempty"`
 	Iridium         BaseJobSettings `toml:"iridium,omitempty"`
 	Network         BaseJobSettings `toml:"network,omitempty"`
+	Retry           RetrySettings   `toml:"retry,omitempty"`
+	// How long running jobs may take to finish before a shutdown or reboot
+	DrainTimeout TOMLDuration `toml:"drain_timeout,omitempty"`
+	// Hold back captures until chrony or the GNSS receiver confirmed the system time
+	RequireTimeSync bool `toml:"require_time_sync,omitempty"`
+	// Overrides the scheduler resource capacities e.g. SDRDevice = 2
+	Resources map[string]int `toml:"resources,omitempty" comment:"scheduler resource capacities (SDRDevice, CPUShare, DiskWriteBandwidth)"`
+	// Local job files, written results instead of server updates
+	Spool SpoolSettings `toml:"spool,omitempty"`
+	// Limits of the checks before a job starts
+	Preflight PreflightSettings `toml:"preflight,omitempty"`
 }
 
 type JobConfigManager struct {
@@ -39,6 +139,13 @@ type JobConfigManager struct {
 
 // Verify verifies the "hard" conditions that the rest of the code relies on
 func (a *JobConfigManager) Verify() error {
+	// Verify the resource names, a typo would leave the resource at its default capacity
+	for name := range a.conf.Resources {
+		if !slices.Contains(ResourceNames, name) {
+			return fmt.Errorf("unknown scheduler resource %q, known are %v", name, ResourceNames)
+		}
+	}
+
 	return nil
 }
 
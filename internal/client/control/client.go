@@ -0,0 +1,126 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+)
+
+// The host is ignored, every request goes to the socket
+const baseURL = "http://client"
+
+// Client talks to a running client over its control socket
+type Client struct {
+	http *http.Client
+}
+
+// NewClient returns a client for the control socket at path, requests give up after timeout
+func NewClient(path string, timeout time.Duration) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}
+
+	return &Client{http: &http.Client{Transport: transport, Timeout: timeout}}
+}
+
+func (c *Client) Tasks() (scheduler.Snapshot, error) {
+	var snap scheduler.Snapshot
+	return snap, c.do(http.MethodGet, TasksPath, nil, &snap)
+}
+
+// Submit schedules a local job and returns it with its id
+func (c *Client) Submit(job api.FixedJob) (api.FixedJob, error) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return job, err
+	}
+
+	var submitted api.FixedJob
+	return submitted, c.do(http.MethodPost, JobsPath, body, &submitted)
+}
+
+// Check returns the verdict of a dry run of the job
+func (c *Client) Check(job api.FixedJob) (api.Verdict, error) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return api.Verdict{}, err
+	}
+
+	var verdict api.Verdict
+	return verdict, c.do(http.MethodPost, CheckPath, body, &verdict)
+}
+
+func (c *Client) Cancel(id string) error {
+	return c.do(http.MethodDelete, JobsPath+"/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *Client) Checkin() error {
+	return c.do(http.MethodPost, CheckinPath, nil, nil)
+}
+
+func (c *Client) Poll() error {
+	return c.do(http.MethodPost, PollPath, nil, nil)
+}
+
+// Config returns the effective configuration as TOML
+func (c *Client) Config() ([]byte, error) {
+	var conf []byte
+	return conf, c.do(http.MethodGet, ConfigPath, nil, &conf)
+}
+
+func (c *Client) Health() (Health, error) {
+	var health Health
+	return health, c.do(http.MethodGet, HealthPath, nil, &health)
+}
+
+// do sends a request and decodes the response into out, raw responses are read into a *[]byte
+func (c *Client) do(method string, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var e errorResponse
+		if json.Unmarshal(data, &e) == nil && len(e.Error) != 0 {
+			return fmt.Errorf("%s (%d)", e.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("control request failed with %s", resp.Status)
+	}
+
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*out = data
+		return nil
+	default:
+		return json.Unmarshal(data, out)
+	}
+}
@@ -0,0 +1,92 @@
+package control
+
+// The control socket lets local administrators inspect and steer the running client, see cmd/clientctl.
+// Requests are plain HTTP with JSON bodies over a unix socket, only the file permissions of the socket guard it.
+
+import (
+	"errors"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+)
+
+// Endpoints of the control socket
+const (
+	TasksPath   = "/tasks"
+	JobsPath    = "/jobs"
+	CheckPath   = "/jobs/check"
+	CheckinPath = "/checkin"
+	PollPath    = "/poll"
+	ConfigPath  = "/config"
+	HealthPath  = "/health"
+)
+
+var ErrJobNotFound = errors.New("job has no tasks")
+
+// Handler executes the requests of the control socket, it is implemented by the job handler
+type Handler interface {
+	Snapshot() scheduler.Snapshot
+	// Submit schedules a local job, its results are kept in the local spool directory
+	Submit(job api.FixedJob) (api.FixedJob, error)
+	// DryRun checks if a job could run without scheduling it
+	DryRun(job api.FixedJob) api.Verdict
+	// CancelJob cancels all tasks of a job, ErrJobNotFound if it has none
+	CancelJob(id string) error
+	Checkin() error
+	// Poll fetches the jobs from the server right away, even if they are pushed
+	Poll() error
+	// Config returns the effective configuration as TOML
+	Config() ([]byte, error)
+	Health() Health
+}
+
+// Health is the state of the services the client depends on, unavailable services are nil
+type Health struct {
+	Sensor  string `json:"sensor"`
+	Version string `json:"version"`
+
+	GNSS      *GNSSHealth     `json:"gnss"`
+	Network   *NetworkHealth  `json:"network"`
+	OTA       *OTAHealth      `json:"ota"`
+	USB       *USBHealth      `json:"usb"`
+	Scheduler SchedulerHealth `json:"scheduler"`
+}
+
+type GNSSHealth struct {
+	TimeValid bool    `json:"time_valid"`
+	Fix       bool    `json:"fix"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	AltMSL    float64 `json:"alt_msl"`
+}
+
+type NetworkHealth struct {
+	Connectivity bool   `json:"connectivity"`
+	GSM          string `json:"gsm,omitempty"`
+	WiFi         string `json:"wifi,omitempty"`
+	Ethernet     string `json:"ethernet,omitempty"`
+}
+
+type OTAHealth struct {
+	Slots string `json:"slots"`
+}
+
+type USBHealth struct {
+	Hotplug bool     `json:"hotplug"`
+	Devices []string `json:"devices"`
+}
+
+type SchedulerHealth struct {
+	Queued     int  `json:"queued"`
+	Running    int  `json:"running"`
+	Recurring  int  `json:"recurring"`
+	TimeSynced bool `json:"time_synced"`
+	Draining   bool `json:"draining"`
+	// Set if the jobs are pushed by the server
+	Pushed bool `json:"pushed"`
+}
+
+// errorResponse is sent along with every failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,201 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+// Submitted jobs are small, anything larger is refused
+const maxRequestSize = 1024 * 1024
+
+var ErrSocketInUse = errors.New("control socket is used by another client")
+
+// Server serves the control socket
+type Server struct {
+	path     string
+	handler  Handler
+	listener net.Listener
+	server   *http.Server
+}
+
+// Listen creates the control socket at path and serves it until Close, only the user of the client,
+// root and the members of group (if set) have access to it
+func Listen(path string, group string, handler Handler) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	// A socket left behind by a crash is replaced, a live one is not
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, ErrSocketInUse
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := restrict(path, group); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	s := &Server{path: path, handler: handler, listener: listener}
+	s.server = &http.Server{Handler: s.routes(), ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("control socket stopped", zap.String("path", path), zap.Error(err))
+		}
+	}()
+
+	log.Info("control socket listening", zap.String("path", path))
+	return s, nil
+}
+
+// restrict limits the access to the socket to its owner and the group
+func restrict(path string, group string) error {
+	if len(group) == 0 {
+		return os.Chmod(path, 0600)
+	}
+
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return err
+	}
+	if err := os.Chown(path, -1, gid); err != nil {
+		return err
+	}
+
+	return os.Chmod(path, 0660)
+}
+
+// Close stops serving and removes the socket
+func (s *Server) Close() error {
+	err := s.server.Close()
+	_ = os.Remove(s.path)
+
+	return err
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+TasksPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.handler.Snapshot())
+	})
+
+	mux.HandleFunc("POST "+JobsPath, func(w http.ResponseWriter, r *http.Request) {
+		var job api.FixedJob
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&job); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		job, err := s.handler.Submit(job)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var invalid *registry.ValidationError
+			if errors.As(err, &invalid) {
+				status = http.StatusUnprocessableEntity
+			}
+			writeError(w, status, err)
+			return
+		}
+
+		log.Info("job submitted over the control socket", zap.String("id", job.Id))
+		writeJSON(w, http.StatusCreated, job)
+	})
+
+	mux.HandleFunc("POST "+CheckPath, func(w http.ResponseWriter, r *http.Request) {
+		var job api.FixedJob
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&job); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, s.handler.DryRun(job))
+	})
+
+	mux.HandleFunc("DELETE "+JobsPath+"/{id...}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if err := s.handler.CancelJob(id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrJobNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+
+		log.Info("job cancelled over the control socket", zap.String("id", id))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("POST "+CheckinPath, func(w http.ResponseWriter, r *http.Request) {
+		if err := s.handler.Checkin(); err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("POST "+PollPath, func(w http.ResponseWriter, r *http.Request) {
+		if err := s.handler.Poll(); err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET "+ConfigPath, func(w http.ResponseWriter, r *http.Request) {
+		conf, err := s.handler.Config()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/toml")
+		_, _ = w.Write(conf)
+	})
+
+	mux.HandleFunc("GET "+HealthPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.handler.Health())
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("could not write control response", zap.Error(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,134 @@
+package control
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeHandler struct {
+	submitted []api.FixedJob
+	cancelled []string
+	polls     int
+}
+
+func (f *fakeHandler) Snapshot() scheduler.Snapshot {
+	return scheduler.Snapshot{Queued: []scheduler.TaskInfo{{ID: "42", State: scheduler.StateQueued}}}
+}
+
+func (f *fakeHandler) Submit(job api.FixedJob) (api.FixedJob, error) {
+	if job.Command == "unknown" {
+		return job, &registry.ValidationError{Command: job.Command}
+	}
+
+	job.Id = "local-1"
+	f.submitted = append(f.submitted, job)
+	return job, nil
+}
+
+func (f *fakeHandler) DryRun(job api.FixedJob) api.Verdict {
+	return api.Verdict{Runnable: job.Command != "unknown", Checks: []api.CheckResult{{Name: "arguments", Status: "passed"}}}
+}
+
+func (f *fakeHandler) CancelJob(id string) error {
+	if id != "42" {
+		return ErrJobNotFound
+	}
+
+	f.cancelled = append(f.cancelled, id)
+	return nil
+}
+
+func (f *fakeHandler) Checkin() error {
+	return errors.New("server unreachable")
+}
+
+func (f *fakeHandler) Poll() error {
+	f.polls++
+	return nil
+}
+
+func (f *fakeHandler) Config() ([]byte, error) {
+	return []byte("[client]\nsensor_name = 'sensor'\n"), nil
+}
+
+func (f *fakeHandler) Health() Health {
+	return Health{Sensor: "sensor", USB: &USBHealth{Devices: []string{"HackRF One"}}}
+}
+
+func TestControlSocket(t *testing.T) {
+	log.Init(true)
+
+	path := filepath.Join(t.TempDir(), "run", "control.sock")
+	handler := &fakeHandler{}
+	server, err := Listen(path, "", handler)
+	assert.NoError(t, err)
+
+	// Only the owner may connect
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// A second client must not take over the socket
+	_, err = Listen(path, "", handler)
+	assert.ErrorIs(t, err, ErrSocketInUse)
+
+	c := NewClient(path, time.Second)
+
+	snap, err := c.Tasks()
+	assert.NoError(t, err)
+	assert.Len(t, snap.Queued, 1)
+	assert.Equal(t, "42", snap.Queued[0].ID)
+
+	job, err := c.Submit(api.FixedJob{Command: "get_status"})
+	assert.NoError(t, err)
+	assert.Equal(t, "local-1", job.Id)
+	assert.Len(t, handler.submitted, 1)
+
+	_, err = c.Submit(api.FixedJob{Command: "unknown"})
+	assert.ErrorContains(t, err, "422")
+
+	verdict, err := c.Check(api.FixedJob{Command: "get_status"})
+	assert.NoError(t, err)
+	assert.True(t, verdict.Runnable)
+	assert.Len(t, verdict.Checks, 1)
+	verdict, err = c.Check(api.FixedJob{Command: "unknown"})
+	assert.NoError(t, err)
+	assert.False(t, verdict.Runnable)
+	assert.Len(t, handler.submitted, 1)
+
+	assert.NoError(t, c.Cancel("42"))
+	assert.Equal(t, []string{"42"}, handler.cancelled)
+	assert.ErrorContains(t, c.Cancel("43"), ErrJobNotFound.Error())
+
+	assert.ErrorContains(t, c.Checkin(), "server unreachable")
+	assert.NoError(t, c.Poll())
+	assert.Equal(t, 1, handler.polls)
+
+	conf, err := c.Config()
+	assert.NoError(t, err)
+	assert.Contains(t, string(conf), "sensor_name")
+
+	health, err := c.Health()
+	assert.NoError(t, err)
+	assert.Equal(t, "sensor", health.Sensor)
+	assert.Nil(t, health.GNSS)
+	assert.Equal(t, []string{"HackRF One"}, health.USB.Devices)
+
+	// The socket is gone once the server closed, a stale one is replaced
+	assert.NoError(t, server.Close())
+	assert.NoFileExists(t, path)
+
+	assert.NoError(t, os.WriteFile(path, nil, 0600))
+	server, err = Listen(path, "", handler)
+	assert.NoError(t, err)
+	assert.NoError(t, server.Close())
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+)
+
+var ErrInvalidComposite = errors.New("invalid composite job")
+
+// stepID returns the task id of a step of a composite job
+func stepID(job api.FixedJob, step string) string {
+	return job.Id + "/" + step
+}
+
+// stepJob derives the job that executes a single step of a composite job
+// The id and steps are kept so the dependencies can be rebuilt from the journal
+func stepJob(job api.FixedJob, step api.JobStep) api.FixedJob {
+	derived := job
+	derived.Command = step.Command
+	derived.Arguments = step.Arguments
+	derived.Step = step.Name
+	return derived
+}
+
+// validateSteps checks that step names are unique and only refer to earlier steps, which keeps the chain acyclic
+func validateSteps(job api.FixedJob) error {
+	if job.Recurrence != nil {
+		return fmt.Errorf("%w: composite jobs can not recur", ErrInvalidComposite)
+	}
+
+	seen := make(map[string]bool, len(job.Steps))
+	for _, step := range job.Steps {
+		if len(step.Name) == 0 || seen[step.Name] {
+			return fmt.Errorf("%w: step name %q is empty or not unique", ErrInvalidComposite, step.Name)
+		}
+
+		for _, dep := range append(append([]string{}, step.After...), step.AfterAny...) {
+			if !seen[dep] {
+				return fmt.Errorf("%w: step %q has to follow an earlier step, not %q", ErrInvalidComposite, step.Name, dep)
+			}
+		}
+
+		seen[step.Name] = true
+	}
+
+	return nil
+}
+
+// withStepDependencies adds the dependencies of the step the job executes to its task
+func withStepDependencies(task *scheduler.Task, job api.FixedJob) error {
+	for _, step := range job.Steps {
+		if step.Name != job.Step {
+			continue
+		}
+
+		for _, dep := range step.After {
+			task.WithDependency(stepID(job, dep), scheduler.OnSuccess)
+		}
+		for _, dep := range step.AfterAny {
+			task.WithDependency(stepID(job, dep), scheduler.OnCompletion)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: unknown step %q", ErrInvalidComposite, job.Step)
+}
+
+// stepParameters returns the job parameters of a single step
+func stepParameters(params *schema.JobParameters, step api.JobStep) *schema.JobParameters {
+	return &schema.JobParameters{
+		Job:    stepJob(params.Job.(api.FixedJob), step),
+		App:    params.App,
+		Config: params.Config,
+		Spool:  params.Spool,
+		Sink:   params.Sink,
+	}
+}
+
+// validateStepArguments checks the arguments of all steps, so the composite job is rejected with every violation at once
+func (h *TaskHandler) validateStepArguments(params *schema.JobParameters) error {
+	job := params.Job.(api.FixedJob)
+	rejected := &registry.ValidationError{Command: job.Command}
+
+	for _, step := range job.Steps {
+		err := h.backend.ValidateJob(stepParameters(params, step))
+
+		var invalid *registry.ValidationError
+		if errors.As(err, &invalid) {
+			for _, v := range invalid.Violations {
+				rejected.Violations = append(rejected.Violations, registry.Violation{Argument: step.Name + "/" + v.Argument, Problem: v.Problem})
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if len(rejected.Violations) != 0 {
+		return rejected
+	}
+
+	return nil
+}
+
+// planComposite expands a composite job into a chain of tasks that is scheduled as a whole
+func (h *TaskHandler) planComposite(params *schema.JobParameters) (plan, error) {
+	job := params.Job.(api.FixedJob)
+	if err := validateSteps(job); err != nil {
+		return plan{}, err
+	}
+
+	if err := h.validateStepArguments(params); err != nil {
+		return plan{}, err
+	}
+
+	p := plan{tasks: make([]*scheduler.Task, 0, len(job.Steps)), group: true}
+	for _, step := range job.Steps {
+		task, err := h.newTask(stepParameters(params, step))
+		if err != nil {
+			return plan{}, err
+		}
+
+		p.tasks = append(p.tasks, task)
+	}
+
+	return p, nil
+}
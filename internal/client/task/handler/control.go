@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/constants"
+	"github.com/LeoCommon/client/internal/client/control"
+	"github.com/LeoCommon/client/internal/client/task/jobs/backend"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
+	"github.com/LeoCommon/client/internal/client/task/jobs/spool"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/LeoCommon/client/pkg/system/services/net"
+)
+
+// The handler serves the requests of the control socket
+var _ control.Handler = (*TaskHandler)(nil)
+
+// Snapshot returns the state of the scheduler
+func (h *TaskHandler) Snapshot() scheduler.Snapshot {
+	return h.scheduler.Snapshot()
+}
+
+// Submit schedules a job of a local administrator, it is treated like a job of the local spool directory
+// but never needs a signature
+func (h *TaskHandler) Submit(job api.FixedJob) (api.FixedJob, error) {
+	if len(job.Id) == 0 {
+		job.Id = fmt.Sprintf("local-%d", h.clock.Now().UnixNano())
+	}
+
+	dir := h.app.Conf.Job().C().Spool.Directory()
+	if err := os.MkdirAll(filepath.Join(dir, spool.JobsFolder), 0750); err != nil {
+		return job, err
+	}
+
+	// Access to the control socket is as good as a signature
+	return job, h.scheduleLocal(job, dir, spool.NewOutbox(dir, ""))
+}
+
+// CancelJob cancels all tasks of a job on behalf of a local administrator, the cancellation is
+// reported like a cancellation by the server
+func (h *TaskHandler) CancelJob(id string) error {
+	snap := h.scheduler.Snapshot()
+	tasks := slices.Concat(snap.Queued, snap.Running, snap.Recurring)
+
+	h.Lock()
+	defer h.Unlock()
+
+	if !hasTasks(tasks, id) {
+		return control.ErrJobNotFound
+	}
+
+	job, sink := api.FixedJob{Id: id}, api.JobSink(h.app.Api)
+	if tracked, ok := h.jobs[id]; ok {
+		job = tracked
+	} else if spooled, ok := h.spooled[id]; ok {
+		job, sink = spooled.job, spooled.sink
+	}
+
+	// The tasks might have ended since the snapshot, the job is only forgotten once they are cancelled
+	if !h.cancelTasks(job) {
+		return control.ErrJobNotFound
+	}
+	delete(h.jobs, id)
+	delete(h.spooled, id)
+
+	log.Info("job was cancelled locally", zap.String("id", id))
+
+	// Running tasks acknowledge the cancellation with their own result
+	if !hasTasks(snap.Running, id) {
+		h.report(sink, job, "cancelled", result.CodeCanceled, "local")
+	}
+
+	return nil
+}
+
+// Config returns the effective configuration
+func (h *TaskHandler) Config() ([]byte, error) {
+	return h.app.Conf.Effective()
+}
+
+// Health collects the state of the services the jobs depend on
+func (h *TaskHandler) Health() control.Health {
+	snap := h.scheduler.Snapshot()
+	health := control.Health{
+		Sensor:  h.app.Conf.SensorName(),
+		Version: constants.ClientServiceVersion,
+		Scheduler: control.SchedulerHealth{
+			Queued:     len(snap.Queued),
+			Running:    len(snap.Running),
+			Recurring:  len(snap.Recurring),
+			TimeSynced: snap.TimeSynced,
+			Draining:   snap.Draining,
+		},
+	}
+
+	if push, ok := h.backend.(backend.Push); ok {
+		health.Scheduler.Pushed = push.Connected()
+	}
+
+	if gnss := h.app.GNSSService; gnss != nil {
+		data := gnss.GetData()
+		health.GNSS = &control.GNSSHealth{
+			TimeValid: gnss.IsGPSTimeValid(),
+			Fix:       data.Valid(),
+			Lat:       data.Lat,
+			Lon:       data.Lon,
+			AltMSL:    data.AltMSL,
+		}
+	}
+
+	if network := h.app.NetworkService; network != nil {
+		health.Network = &control.NetworkHealth{Connectivity: network.HasConnectivity()}
+		health.Network.GSM, _ = network.GetConnectionStateStr(net.GSM)
+		health.Network.WiFi, _ = network.GetConnectionStateStr(net.WiFi)
+		health.Network.Ethernet, _ = network.GetConnectionStateStr(net.Ethernet)
+	}
+
+	if ota := h.app.OtaService; ota != nil {
+		health.OTA = &control.OTAHealth{Slots: ota.SlotStatiString()}
+	}
+
+	if devices := h.app.UsbManager; devices != nil {
+		health.USB = &control.USBHealth{Hotplug: devices.HotplugActive(), Devices: []string{}}
+		for _, d := range devices.Devices() {
+			health.USB.Devices = append(health.USB.Devices, d.String())
+		}
+	}
+
+	return health
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/control"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelJob(t *testing.T) {
+	log.Init(true)
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, server := newTestHandler(t, now)
+
+	job := api.FixedJob{Id: "42", Name: "status", Command: "get_status", StartTime: now.Add(time.Minute), EndTime: now.Add(time.Hour)}
+	h.jobs[job.Id] = job
+
+	// Without tasks the job stays tracked, e.g. for the next reconciliation
+	assert.ErrorIs(t, h.CancelJob(job.Id), control.ErrJobNotFound)
+	assert.Contains(t, h.jobs, job.Id)
+
+	noop := func(context.Context, interface{}) error { return nil }
+	assert.NoError(t, h.scheduler.Schedule(scheduler.NewTask(job.StartTime, job.EndTime, noop, nil).WithID(job.Id)))
+
+	assert.NoError(t, h.CancelJob(job.Id))
+	assert.NotContains(t, h.jobs, job.Id)
+	assert.Empty(t, h.scheduler.Snapshot().Queued)
+
+	// Queued tasks are acknowledged by the handler
+	assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "cancelled(local)", server.received()[0].Status)
+}
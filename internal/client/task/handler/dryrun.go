@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+// verdict collects the checks of a dry run, once a check failed the later ones are skipped
+type verdict struct {
+	api.Verdict
+	failed bool
+}
+
+// check runs a check that returns the result code of its failure
+func (v *verdict) check(name string, run func() (result.Code, error)) {
+	r := api.CheckResult{Name: name, Status: preflight.StatusPassed}
+	if v.failed {
+		r.Status = preflight.StatusSkipped
+	} else if code, err := run(); err != nil {
+		r.Status = preflight.StatusFailed
+		r.Code = string(code)
+		r.Message = err.Error()
+	}
+
+	v.add(r)
+}
+
+func (v *verdict) add(r api.CheckResult) {
+	v.Checks = append(v.Checks, r)
+	v.failed = v.failed || r.Status == preflight.StatusFailed
+}
+
+// dryRun checks if the job could run right now without scheduling or executing it,
+// trusted jobs are not checked against the signing policy
+func (h *TaskHandler) dryRun(params *schema.JobParameters, trusted bool) api.Verdict {
+	job := params.Job.(api.FixedJob)
+	v := &verdict{Verdict: api.Verdict{Checks: []api.CheckResult{}}}
+
+	if trusted {
+		v.add(api.CheckResult{Name: "signature", Status: preflight.StatusSkipped, Message: "submitted locally"})
+	} else {
+		v.check("signature", func() (result.Code, error) {
+			verified, err := h.verifier.Verify(job, h.clock.Now())
+			job = verified
+			params.Job = verified
+			return result.CodeInvalidSignature, err
+		})
+	}
+
+	v.check("window", func() (result.Code, error) {
+		if len(job.Id) == 0 {
+			return result.CodeFailed, ErrNoJobID
+		}
+		if jobExpired(job, h.clock.Now()) {
+			return result.CodeExpired, scheduler.ErrTaskExpired
+		}
+
+		return result.CodeNone, nil
+	})
+
+	// Creating the tasks validates the command and its arguments
+	var p plan
+	v.check("arguments", func() (result.Code, error) {
+		var err error
+		p, err = h.plan(params)
+
+		var invalid *registry.ValidationError
+		switch {
+		case errors.Is(err, ErrNoHandler):
+			return result.CodeUnsupported, err
+		case errors.As(err, &invalid):
+			return result.CodeInvalidArguments, err
+		}
+
+		return result.CodeSchedulingError, err
+	})
+
+	v.check("admission", func() (result.Code, error) {
+		var err error
+		if p.recurring != nil {
+			v.Preempts, err = h.scheduler.CheckRecurringAdmission(p.recurring)
+		} else {
+			v.Preempts, err = h.scheduler.CheckAdmission(p.tasks...)
+		}
+
+		return result.Classify(err), err
+	})
+
+	// The checks the job type declares, composite jobs run those of every step
+	targets := []*schema.JobParameters{params}
+	if p.group {
+		targets = targets[:0]
+		for _, task := range p.tasks {
+			targets = append(targets, task.Argument.(*schema.JobParameters))
+		}
+	}
+
+	for _, target := range targets {
+		prefix := ""
+		if step := target.Job.(api.FixedJob).Step; len(step) != 0 {
+			prefix = step + "/"
+		}
+
+		if v.failed {
+			for _, check := range h.backend.GetPreflightChecks(target) {
+				v.add(api.CheckResult{Name: prefix + check.Name, Status: preflight.StatusSkipped})
+			}
+			continue
+		}
+
+		results, _ := h.preflight(target, jobWindow(job))
+		for _, r := range results {
+			r.Name = prefix + r.Name
+			v.add(r)
+		}
+	}
+
+	v.Runnable = !v.failed
+	return v.Verdict
+}
+
+// reportDryRun checks the job and reports the verdict to the sink instead of scheduling it
+func (h *TaskHandler) reportDryRun(sink api.JobSink, params *schema.JobParameters) {
+	job := params.Job.(api.FixedJob)
+	verdict := h.dryRun(params, false)
+
+	res := result.New(job, "dry_run", nil, h.clock.Now())
+	res.Verdict = &verdict
+
+	verb := "dry_run(runnable)"
+	for _, check := range verdict.Checks {
+		if check.Status == preflight.StatusFailed {
+			res.Code = check.Code
+			res.Message = check.Message
+			verb = "dry_run(not_runnable:" + check.Name + ")"
+			break
+		}
+	}
+
+	log.Info("dry run of job", zap.String("id", job.Id), zap.Bool("runnable", verdict.Runnable), zap.String("code", res.Code))
+	h.sendResult(sink, job, verb, res)
+}
+
+// DryRun checks a job of a local administrator without scheduling it, like Submit it needs no signature
+func (h *TaskHandler) DryRun(job api.FixedJob) api.Verdict {
+	params := &schema.JobParameters{
+		Job:    job,
+		App:    h.app,
+		Config: h.app.Conf.Job().C(),
+	}
+
+	return h.dryRun(params, true)
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client"
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/internal/client/task/jobs/backend"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/clock"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// update is a job update the test server received
+type update struct {
+	Status string
+	Result api.JobResult
+}
+
+// testServer records the job updates of the sensor "sensor"
+type testServer struct {
+	m       sync.Mutex
+	updates []update
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.NotFound(w, r)
+		return
+	}
+
+	u := update{Status: r.URL.Query().Get("status")}
+	if body, _ := io.ReadAll(r.Body); len(body) != 0 {
+		_ = json.Unmarshal(body, &u.Result)
+	}
+
+	s.m.Lock()
+	s.updates = append(s.updates, u)
+	s.m.Unlock()
+}
+
+func (s *testServer) received() []update {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]update(nil), s.updates...)
+}
+
+// newTestHandler returns a handler that reports to a test server through the rest api
+func newTestHandler(t *testing.T, now time.Time) (*TaskHandler, *testServer) {
+	t.Helper()
+
+	server := &testServer{}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	assert.NoError(t, os.WriteFile(path, []byte("[client]\nsensor_name = 'sensor'\n[api]\nurl = '"+ts.URL+"/'\n[jobs]\nstorage_path = '"+dir+"'\n"), 0644))
+
+	conf := config.NewManager()
+	assert.NoError(t, conf.Load(path, false))
+
+	a, err := api.NewRestAPI(conf, false)
+	assert.NoError(t, err)
+
+	rest, err := backend.NewRestAPIBackend(a)
+	assert.NoError(t, err)
+
+	clk := clock.NewFake(now)
+	h := &TaskHandler{
+		backend:   rest,
+		scheduler: scheduler.NewScheduler(1).WithClock(clk),
+		app:       &client.App{Api: a, Conf: conf},
+		clock:     clk,
+		jobs:      make(map[string]api.FixedJob),
+		spooled:   make(map[string]spooledJob),
+	}
+	go h.scheduler.Run()
+	t.Cleanup(h.scheduler.Shutdown)
+
+	return h, server
+}
+
+func TestReportDryRun(t *testing.T) {
+	log.Init(true)
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, server := newTestHandler(t, now)
+
+	params := func(job api.FixedJob) *schema.JobParameters {
+		return &schema.JobParameters{Job: job, App: h.app, Config: h.app.Conf.Job().C()}
+	}
+
+	job := api.FixedJob{Id: "42", Name: "status", Command: "get_status", StartTime: now.Add(time.Minute), EndTime: now.Add(time.Hour), DryRun: true}
+	h.reportDryRun(h.app.Api, params(job))
+	assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, 10*time.Millisecond)
+
+	runnable := server.received()[0]
+	assert.Equal(t, "dry_run(runnable)", runnable.Status)
+	assert.Equal(t, "42", runnable.Result.JobID)
+	if assert.NotNil(t, runnable.Result.Verdict) {
+		assert.True(t, runnable.Result.Verdict.Runnable)
+		assert.NotEmpty(t, runnable.Result.Verdict.Checks)
+	}
+
+	// Unknown commands fail the argument check, the later checks are skipped
+	unknown := api.FixedJob{Id: "43", Name: "unknown", Command: "no_such_command", StartTime: now.Add(time.Minute), EndTime: now.Add(time.Hour), DryRun: true}
+	h.reportDryRun(h.app.Api, params(unknown))
+	assert.Eventually(t, func() bool { return len(server.received()) == 2 }, time.Second, 10*time.Millisecond)
+
+	rejected := server.received()[1]
+	assert.Equal(t, "dry_run(not_runnable:arguments)", rejected.Status)
+	assert.Equal(t, string(result.CodeUnsupported), rejected.Result.Code)
+	if assert.NotNil(t, rejected.Result.Verdict) {
+		assert.False(t, rejected.Result.Verdict.Runnable)
+		for _, check := range rejected.Result.Verdict.Checks {
+			if check.Name == "admission" {
+				assert.Equal(t, preflight.StatusSkipped, check.Status)
+			}
+		}
+	}
+
+	// Nothing was scheduled
+	assert.Empty(t, h.scheduler.Snapshot().Queued)
+}
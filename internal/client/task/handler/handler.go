@@ -3,33 +3,99 @@ package handler
 // This defines a generic handler that manages jobs
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/LeoCommon/client/internal/client"
 	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
 	"github.com/LeoCommon/client/internal/client/task/jobs"
 	"github.com/LeoCommon/client/internal/client/task/jobs/backend"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
 	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/jobs/signing"
 	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/clock"
 	"github.com/LeoCommon/client/pkg/log"
+	"github.com/LeoCommon/client/pkg/misc"
+	"github.com/LeoCommon/client/pkg/progress"
+	"github.com/LeoCommon/client/pkg/system/cli"
+	"github.com/LeoCommon/client/pkg/usb"
 )
 
+// The scheduler journal is kept next to the job data so it survives reboots
+const JournalFileName = "scheduler_journal.json"
+
+// Stack traces of crashed jobs are kept next to the journal
+const CrashLogFileName = "crash.log"
+
+// Running jobs push their progress to the server at most this often
+const ProgressInterval = 10 * time.Second
+
+// How often the system time is checked until it was confirmed
+const TimeSyncInterval = 10 * time.Second
+
+var ErrNoHandler = errors.New("no handler for job")
+var ErrNoJobID = errors.New("job has no id")
+
+// Errors that are usually gone after a while, e.g. the SDR was briefly busy, an upload timed out or the sensor was too hot
+var retryableErrors = []error{&usb.StuckError{}, &misc.TimedOutError{}, preflight.ErrFailed}
+
 type TaskHandler struct {
 	sync.RWMutex
 	backend   backend.Backend
 	scheduler *scheduler.Scheduler
 	app       *client.App
+	clock     clock.Clock
+	// Set once the system time was confirmed
+	timeConfirmed atomic.Bool
+	// The server jobs with tasks in the scheduler by id, see reconcile
+	jobs map[string]api.FixedJob
+	// The spooled and locally submitted jobs by id, the server does not know them
+	spooled map[string]spooledJob
+	// Polls and pushed events are processed one at a time
+	syncing sync.Mutex
+	// Checks the job signatures, nil unless a key is pinned
+	verifier *signing.Verifier
+	// Reads the job files of the spool directories, nil unless enabled
+	spool backend.Spool
+	// Stops the job stream and the spool
+	stopSources context.CancelFunc
 }
 
 func (h *TaskHandler) Shutdown() {
+	if h.stopSources != nil {
+		h.stopSources()
+	}
 	h.scheduler.Shutdown()
 }
 
+// Drain stops accepting jobs and lets the running ones finish within the drain timeout,
+// the queued jobs are kept in the journal and restored after the restart
+func (h *TaskHandler) Drain() {
+	held := h.scheduler.Drain(h.app.Conf.Job().C().DrainDuration())
+	for _, info := range held {
+		log.Info("job held back by the drain, it will be restored after the restart", zap.String("id", info.ID), zap.Time("start", info.StartTime))
+	}
+}
+
+// Draining returns true once the handler stopped accepting jobs
+func (h *TaskHandler) Draining() bool {
+	return h.scheduler.Draining()
+}
+
 // Performs a checkin operation with the server
 func (h *TaskHandler) Checkin() error {
 	status, err := jobs.GetDefaultSensorStatus(h.app)
@@ -37,28 +103,137 @@ func (h *TaskHandler) Checkin() error {
 		return err
 	}
 
-	// Try to "check-in" with the server
+	// Try to "check-in" with the server, the updates queued while offline are replayed first
 	return h.app.Api.PutSensorUpdate(status)
 }
 
+// Asynchronously send the final status of a job that did not run, along with the structured result
+func (h *TaskHandler) report(sink api.JobSink, job api.FixedJob, status string, code result.Code, details string) {
+	res := result.New(job, status, nil, h.clock.Now())
+	res.Code = string(code)
+	res.Message = details
+
+	verb := status
+	if len(details) != 0 {
+		verb = status + "(" + strings.ReplaceAll(details, " ", "_") + ")"
+	}
+
+	h.sendResult(sink, job, verb, res)
+}
+
+// sendResult delivers the final update in the background, the api keeps it in the outbox while the server is unreachable
+func (h *TaskHandler) sendResult(sink api.JobSink, job api.FixedJob, verb string, res api.JobResult) {
+	go func() {
+		if err := sink.PutJobResult(job, job.StepStatus(verb), res); err != nil {
+			log.Error("could not report job result", zap.String("id", job.Id), zap.String("status", verb), zap.Error(err))
+		}
+	}()
+}
+
+// Asynchronously mark a job as rejected, the reason lists all invalid arguments
+func (h *TaskHandler) MarkRejected(sink api.JobSink, job api.FixedJob, invalid *registry.ValidationError) {
+	res := result.New(job, "rejected", invalid, h.clock.Now())
+	h.sendResult(sink, job, "rejected("+invalid.Reason()+")", res)
+}
+
 // Asynchronously mark a job as failed
-func (h *TaskHandler) MarkFailed(job api.FixedJob, details string) {
-	if len(details) < 1 {
-		go h.app.Api.PutJobUpdate(job.Name, "failed")
-	} else {
-		details = strings.ReplaceAll(details, " ", "_")
-		go h.app.Api.PutJobUpdate(job.Name, "failed("+details+")")
+func (h *TaskHandler) MarkFailed(sink api.JobSink, job api.FixedJob, code result.Code, details string) {
+	h.report(sink, job, "failed", code, details)
+}
+
+// onTaskDone returns the post execution hook of a job, it reports the jobs the scheduler
+// dropped without running them, the job handlers report the results of executed jobs themselves
+func (h *TaskHandler) onTaskDone(params *schema.JobParameters) func(error) {
+	job := params.Job.(api.FixedJob)
+	sink := params.Uplink()
+
+	return func(err error) {
+		var panicErr *scheduler.PanicError
+		switch {
+		case errors.As(err, &panicErr):
+			// The job could not report the failure itself
+			h.MarkFailed(sink, job, result.CodeCrashed, fmt.Sprintf("crashed:%v", panicErr.Value))
+		case errors.Is(err, scheduler.ErrTaskPreempted):
+			// Only queued tasks, the running ones report the preemption themselves
+			log.Warn("job was preempted by a job with a higher priority", zap.String("job", job.Json()))
+			h.report(sink, job, "preempted", result.CodePreempted, "")
+		case errors.Is(err, scheduler.ErrTaskExpired):
+			h.MarkFailed(sink, job, result.CodeExpired, "expired executionTime")
+		case errors.Is(err, scheduler.ErrDependencyFailed):
+			h.MarkFailed(sink, job, result.CodeDependencyFailed, "previous step failed")
+		case err != nil:
+			log.Error("task finished with error", zap.String("job", job.Json()), zap.Error(err))
+		}
+	}
+}
+
+// withProgress passes a reporter to the job function that pushes the progress of the job to the server
+func (h *TaskHandler) withProgress(params *schema.JobParameters, fn scheduler.JobFunction) scheduler.JobFunction {
+	job := params.Job.(api.FixedJob)
+	sink := params.Uplink()
+
+	return func(ctx context.Context, arg interface{}) error {
+		reporter := progress.NewThrottle(progress.ReporterFunc(func(u progress.Update) {
+			go sink.PutJobUpdate(job, job.StepStatus("running("+u.String()+")"))
+		}), ProgressInterval)
+
+		return fn(progress.WithReporter(ctx, reporter), arg)
+	}
+}
+
+// timeSynced checks if chrony or the GNSS receiver confirmed the system time
+func (h *TaskHandler) timeSynced() bool {
+	if synced, err := cli.IsTimeSynchronized(); err == nil && synced {
+		return true
+	}
+
+	return h.app.GNSSService != nil && h.app.GNSSService.IsGPSTimeValid()
+}
+
+// updateTimeSync releases the time critical jobs once the system time was confirmed, returns true if it was
+func (h *TaskHandler) updateTimeSync() bool {
+	if h.timeConfirmed.Load() {
+		return true
 	}
+	if !h.timeSynced() || !h.timeConfirmed.CompareAndSwap(false, true) {
+		return h.timeConfirmed.Load()
+	}
+
+	log.Info("system time confirmed, starting time critical jobs")
+	h.scheduler.SetTimeSynced(true)
+	return true
 }
 
-// CancelJob cancels the job with the given ID.
-// Returns true if the job was found and cancelled, false otherwise.
-func (h *TaskHandler) CancelJob(id string) bool {
-	return h.scheduler.Cancel(id)
+// waitForTimeSync checks the system time until it was confirmed or ctx is done.
+// Offline sensors never check in, so this does not wait for the polls
+func (h *TaskHandler) waitForTimeSync(ctx context.Context) {
+	timer := h.clock.NewTimer(TimeSyncInterval)
+	defer timer.Stop()
+
+	for !h.updateTimeSync() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			timer.Reset(TimeSyncInterval)
+		}
+	}
 }
 
 func (h *TaskHandler) Tick() error {
 	log.Debug("Polling jobs.")
+
+	// The stream delivers the jobs as soon as they are created
+	if push, ok := h.backend.(backend.Push); ok && push.Connected() {
+		log.Debug("jobs are pushed, skipping the poll")
+		return nil
+	}
+
+	return h.Poll()
+}
+
+// Poll fetches the job list from the server and syncs the scheduler with it
+func (h *TaskHandler) Poll() error {
 	newJobs, err := h.app.Api.GetJobs()
 
 	if err != nil {
@@ -66,68 +241,354 @@ func (h *TaskHandler) Tick() error {
 		return err
 	}
 
+	h.sync(newJobs)
+	return nil
+}
+
+// sync reconciles the scheduler with the complete job list of the sensor and schedules the new jobs
+func (h *TaskHandler) sync(newJobs []api.FixedJob) {
+	h.syncing.Lock()
+	defer h.syncing.Unlock()
+
+	// Stop what the server does not want anymore before anything new is scheduled
+	h.reconcile(newJobs)
+	h.scheduleJobs(newJobs)
+}
+
+// scheduleJobs schedules the jobs that are not scheduled yet, must be called with syncing held
+func (h *TaskHandler) scheduleJobs(newJobs []api.FixedJob) {
 	for _, job := range newJobs {
 		params := &schema.JobParameters{}
 		params.Job = job
 		params.App = h.app
 		params.Config = h.app.Conf.Job().C()
 
-		// fixme: as long as we use the task.name as identifier we need it to be set
-		if len(job.Name) == 0 {
-			h.MarkFailed(job, "no jobName")
-			continue
-		}
-
-		// If the jobs endTime is already expired, mark it as failed
-		endTime := job.EndTime
-		if time.Now().After(endTime) {
-			h.MarkFailed(job, "expired executionTime")
+		// Cancelled jobs are never scheduled, reconcile stopped their tasks already
+		myName := h.app.Conf.SensorName()
+		if jobCancelled(job, myName) {
 			continue
 		}
 
 		// If the job is already marked as running for our sensor, also skip it
-		myName := h.app.Conf.SensorName()
 		myStatus := job.States[myName]
 		if (len(myStatus) != 0) && (myStatus != "pending") {
 			log.Debug("skipping to enqueue already running job", zap.String("job", job.Json()))
 			continue
 		}
 
-		// If no job handler was found, mark as failed and continue
-		handlerFunc, exclusiveResources := h.backend.GetJobHandlerFromParameters(params)
-		if handlerFunc == nil {
-			log.Error("no handler for job", zap.String("job", job.Json()))
-			h.MarkFailed(job, "no handler")
+		// Dry runs are only checked and never tracked, the server gets the verdict as the result
+		if job.DryRun {
+			h.reportDryRun(h.app.Api, params)
 			continue
 		}
 
-		// Create a new task object
-		task := scheduler.
-			NewTask(job.StartTime, job.EndTime, handlerFunc, params).
-			WithID(job.Id).
-			WithResource(exclusiveResources...)
-
-		// Schedule it
-		err := h.scheduler.Schedule(task)
+		// Only the signed form of the job is scheduled
+		verified, err := h.verifier.Verify(job, h.clock.Now())
 		if err != nil {
-			// Unstarted tasks are allowed to be updated, so dont error out on AlreadyExists
-			// And if the identical task is already running, we also dont do anything
-			if err == scheduler.ErrTaskAlreadyExists || err == scheduler.ErrTaskAlreadyRunning {
+			// Signatures expire, the job was verified when it was scheduled
+			if h.isTracked(job.Id) {
 				continue
 			}
 
-			log.Error("could not schedule job", zap.Error(err))
-			h.MarkFailed(job, "schedulingError:"+err.Error())
+			h.rejectSignature(h.app.Api, job, err)
 			continue
 		}
+		params.Job = verified
+
+		err = h.admit(params)
+		if err == nil || err == scheduler.ErrTaskAlreadyExists || err == scheduler.ErrTaskAlreadyRunning {
+			h.track(verified)
+		}
+	}
+}
+
+// rejectSignature reports a job that is not signed as the policy requires
+func (h *TaskHandler) rejectSignature(sink api.JobSink, job api.FixedJob, err error) {
+	log.Error("rejected job without valid signature", zap.String("job", job.Json()), zap.Error(err))
+	h.report(sink, job, "rejected", result.CodeInvalidSignature, err.Error())
+}
+
+// admit checks and schedules a job, the jobs that can not run are reported to the uplink of the job
+func (h *TaskHandler) admit(params *schema.JobParameters) error {
+	job := params.Job.(api.FixedJob)
+	sink := params.Uplink()
+
+	// The id identifies the task, its storage and uploads, the name is only displayed
+	if len(job.Id) == 0 {
+		log.Error("job without id", zap.String("job", job.Json()))
+		h.MarkFailed(sink, job, result.CodeFailed, "no jobId")
+		return ErrNoJobID
+	}
+
+	// If the jobs endTime is already expired, mark it as failed
+	if jobExpired(job, h.clock.Now()) {
+		h.MarkFailed(sink, job, result.CodeExpired, "expired executionTime")
+		return scheduler.ErrTaskExpired
+	}
+
+	// Schedule it
+	err := h.schedule(params)
+	if err != nil {
+		// Unstarted tasks are allowed to be updated, so dont error out on AlreadyExists
+		// And if the identical task is already running, we also dont do anything
+		if err == scheduler.ErrTaskAlreadyExists || err == scheduler.ErrTaskAlreadyRunning {
+			return err
+		}
+
+		// If no job handler was found, mark as failed and continue
+		if err == ErrNoHandler {
+			log.Error("no handler for job", zap.String("job", job.Json()))
+			h.MarkFailed(sink, job, result.CodeUnsupported, "no handler")
+			return err
+		}
 
-		// Output some info about the job
-		log.Info("scheduled new job", zap.String("job", job.Json()))
+		// Report all argument problems at once, the job can never run as sent
+		var invalid *registry.ValidationError
+		if errors.As(err, &invalid) {
+			log.Error("rejected job with invalid arguments", zap.String("job", job.Json()), zap.Error(err))
+			h.MarkRejected(sink, job, invalid)
+			return err
+		}
+
+		log.Error("could not schedule job", zap.Error(err))
+		h.MarkFailed(sink, job, result.CodeSchedulingError, "schedulingError:"+err.Error())
+		return err
 	}
 
+	// Output some info about the job
+	log.Info("scheduled new job", zap.String("job", job.Json()))
 	return nil
 }
 
+// jobExpired returns true if the job can not run anymore
+func jobExpired(job api.FixedJob, now time.Time) bool {
+	if job.Recurrence != nil {
+		until := job.Recurrence.UntilTime()
+		return !until.IsZero() && now.After(until)
+	}
+
+	return now.After(job.EndTime)
+}
+
+// plan holds the scheduler tasks of a job before they are queued
+type plan struct {
+	tasks     []*scheduler.Task
+	recurring *scheduler.RecurringTask
+	// The tasks of composite jobs are queued as a group
+	group bool
+}
+
+// plan creates the scheduler tasks of the job parameters without queueing them
+func (h *TaskHandler) plan(params *schema.JobParameters) (plan, error) {
+	job := params.Job.(api.FixedJob)
+	if len(job.Steps) != 0 && len(job.Step) == 0 {
+		return h.planComposite(params)
+	}
+
+	if job.Recurrence != nil {
+		task, err := h.newRecurringTask(params)
+		if err != nil {
+			return plan{}, err
+		}
+
+		return plan{recurring: task}, nil
+	}
+
+	task, err := h.newTask(params)
+	if err != nil {
+		return plan{}, err
+	}
+
+	return plan{tasks: []*scheduler.Task{task}}, nil
+}
+
+// schedule creates the scheduler tasks for the job parameters and queues them
+func (h *TaskHandler) schedule(params *schema.JobParameters) error {
+	p, err := h.plan(params)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case p.recurring != nil:
+		return h.scheduler.ScheduleRecurring(p.recurring)
+	case p.group:
+		return h.scheduler.ScheduleGroup(p.tasks...)
+	default:
+		return h.scheduler.Schedule(p.tasks[0])
+	}
+}
+
+// newRecurringTask creates the scheduler task of a recurring job
+func (h *TaskHandler) newRecurringTask(params *schema.JobParameters) (*scheduler.RecurringTask, error) {
+	params.Clock = h.clock
+	handlerFunc, resources := h.backend.GetJobHandlerFromParameters(params)
+	if handlerFunc == nil {
+		return nil, ErrNoHandler
+	}
+	if err := h.backend.ValidateJob(params); err != nil {
+		return nil, err
+	}
+
+	job := params.Job.(api.FixedJob)
+	rec := job.Recurrence
+	task := scheduler.
+		NewRecurringTask(job.StartTime, jobWindow(job), h.withPreflight(params, jobWindow(job), h.withProgress(params, handlerFunc)), params).
+		WithID(job.Id).
+		WithUntil(rec.UntilTime()).
+		WithResource(resources...).
+		WithPriority(job.Priority).
+		WithRetry(retryPolicy(job, params.Config)).
+		WithPayload(payloadOf(params))
+	task.PostExecute = h.onTaskDone(params)
+	if timeCritical(resources) {
+		task.WithTimeCritical()
+	}
+
+	if len(rec.Cron) != 0 {
+		cron, err := scheduler.ParseCron(rec.Cron)
+		if err != nil {
+			return nil, err
+		}
+		task.WithCron(cron)
+	} else {
+		task.WithInterval(time.Duration(rec.IntervalSeconds) * time.Second)
+	}
+
+	return task, nil
+}
+
+// newTask creates the scheduler task of a single job or composite job step
+func (h *TaskHandler) newTask(params *schema.JobParameters) (*scheduler.Task, error) {
+	params.Clock = h.clock
+	handlerFunc, resources := h.backend.GetJobHandlerFromParameters(params)
+	if handlerFunc == nil {
+		return nil, ErrNoHandler
+	}
+	if err := h.backend.ValidateJob(params); err != nil {
+		return nil, err
+	}
+
+	job := params.Job.(api.FixedJob)
+	id := job.Id
+	if len(job.Step) != 0 {
+		id = stepID(job, job.Step)
+	}
+
+	task := scheduler.
+		NewTask(job.StartTime, job.EndTime, h.withPreflight(params, jobWindow(job), h.withProgress(params, handlerFunc)), params).
+		WithID(id).
+		WithResource(resources...).
+		WithPriority(job.Priority).
+		WithRetry(retryPolicy(job, params.Config)).
+		WithPayload(payloadOf(params))
+	task.PostExecute = h.onTaskDone(params)
+	if timeCritical(resources) {
+		task.WithTimeCritical()
+	}
+
+	if len(job.Step) != 0 {
+		if err := withStepDependencies(task, job); err != nil {
+			return nil, err
+		}
+	}
+
+	return task, nil
+}
+
+// timeCritical returns true for captures, their data is useless without correct timestamps
+func timeCritical(resources scheduler.ResourceClaims) bool {
+	for _, claim := range resources {
+		if claim.Resource == scheduler.SDRDevice {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryPolicy returns the retry settings of the client unless the job overrides them
+func retryPolicy(job api.FixedJob, conf config.JobsConfig) scheduler.RetryPolicy {
+	policy := scheduler.RetryPolicy{
+		MaxAttempts: conf.Retry.Attempts(),
+		Backoff:     conf.Retry.BackoffDuration(),
+		Retryable:   retryableErrors,
+	}
+
+	if job.Retry != nil {
+		policy.MaxAttempts = job.Retry.MaxAttempts
+		if job.Retry.BackoffSeconds > 0 {
+			policy.Backoff = time.Duration(job.Retry.BackoffSeconds) * time.Second
+		}
+	}
+
+	return policy
+}
+
+// payloadOf serializes the parameters so the task survives a restart
+func payloadOf(params *schema.JobParameters) json.RawMessage {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		log.Error("could not serialize job parameters, task will not be persisted", zap.Error(err))
+		return nil
+	}
+
+	return payload
+}
+
+// restoreTask re-schedules a journaled task
+func (h *TaskHandler) restoreTask(record scheduler.TaskRecord) error {
+	params := &schema.JobParameters{App: h.app}
+	if err := json.Unmarshal(record.Payload, params); err != nil {
+		return err
+	}
+	h.attachSink(params)
+
+	// The job might have expired while the client was down
+	job := params.Job.(api.FixedJob)
+	if jobExpired(job, h.clock.Now()) {
+		h.MarkFailed(params.Uplink(), job, result.CodeExpired, "expired executionTime")
+		return fmt.Errorf("journaled job %s expired", record.ID)
+	}
+
+	err := h.schedule(params)
+	if errors.Is(err, scheduler.ErrDependencyNotFound) {
+		// The previous steps of the composite job did not survive the restart
+		log.Warn("composite job was interrupted by a client restart", zap.String("job", job.Json()))
+		h.report(params.Uplink(), job, "interrupted", result.CodeInterrupted, "")
+	}
+	// Spooled jobs are not known to the server, it must not cancel them
+	if err == nil && len(params.Spool) == 0 {
+		h.track(job)
+	} else if err == nil {
+		h.trackSpooled(job, params.Sink)
+	}
+
+	return err
+}
+
+// restore re-schedules the journaled tasks and reports the ones a crash interrupted
+func (h *TaskHandler) restore() {
+	interrupted, err := h.scheduler.Restore(h.restoreTask)
+	if err != nil {
+		log.Error("could not restore scheduler journal", zap.Error(err))
+		return
+	}
+
+	for _, record := range interrupted {
+		params := &schema.JobParameters{App: h.app}
+		if err := json.Unmarshal(record.Payload, params); err != nil {
+			log.Error("could not decode interrupted task", zap.String("id", record.ID), zap.Error(err))
+			continue
+		}
+		h.attachSink(params)
+
+		job := params.Job.(api.FixedJob)
+		log.Warn("job was interrupted by a client restart", zap.String("job", job.Json()))
+		h.report(params.Uplink(), job, "interrupted", result.CodeInterrupted, "")
+	}
+}
+
 // Returns true if any job is currently running
 func (h *TaskHandler) HasRunningJob() bool {
 	return h.scheduler.HasRunningJob()
@@ -136,16 +597,87 @@ func (h *TaskHandler) HasRunningJob() bool {
 func NewJobHandler(app *client.App) (*TaskHandler, error) {
 	jh := &TaskHandler{}
 	jh.app = app
+	jh.clock = clock.Real
+	jh.jobs = make(map[string]api.FixedJob)
+	jh.spooled = make(map[string]spooledJob)
+
+	// Jobs are checked against the pinned signing keys before they are scheduled
+	verifier, err := signing.NewVerifier(app.Conf.Api().C().Signing, app.Conf.SensorName())
+	if err != nil {
+		return nil, err
+	}
+	jh.verifier = verifier
 
 	// Set up the rest api backend
-	backend, err := backend.NewRestAPIBackend(app.Api)
-	jh.backend = backend
+	rest, err := backend.NewRestAPIBackend(app.Api)
+	if err != nil {
+		return nil, err
+	}
+	jh.backend = rest
+
+	// Jobs are pushed while the stream is up and polled otherwise
+	var push backend.Push
+	if app.Conf.Api().C().Push == config.JobPushSSE {
+		push = backend.NewPushBackend(rest, app.Api)
+		jh.backend = push
+	}
+
+	// Keep the updates the server did not get while offline, they are replayed with the next check-in
+	outbox, err := api.NewOutbox(filepath.Join(app.Conf.JobStoragePath(), api.OutboxFileName), api.MaxOutboxEntries)
+	if err != nil {
+		log.Error("could not load the outbox, queued updates are lost", zap.Error(err))
+	}
+	var online func() bool
+	if app.NetworkService != nil {
+		online = app.NetworkService.HasConnectivity
+	}
+	app.Api.EnableOutbox(outbox, online)
 
 	// Set up scheduler with NPROC workers
-	jh.scheduler = scheduler.NewScheduler(runtime.NumCPU())
+	journal := scheduler.NewFileJournal(filepath.Join(app.Conf.JobStoragePath(), JournalFileName))
+	jh.scheduler = scheduler.NewScheduler(runtime.NumCPU()).
+		WithJournal(journal).
+		WithClock(jh.clock).
+		WithCrashLog(filepath.Join(app.Conf.JobStoragePath(), CrashLogFileName))
+	for resource, units := range app.Conf.Job().C().Resources {
+		jh.scheduler.WithCapacity(scheduler.Resource(resource), units)
+	}
+	app.Scheduler = jh.scheduler
+
+	// Without confirmed time the captures have to wait
+	jh.timeConfirmed.Store(!app.Conf.Job().C().RequireTimeSync)
+	if !jh.timeConfirmed.Load() {
+		jh.scheduler.SetTimeSynced(false)
+		jh.updateTimeSync()
+	}
+
+	// Pick up where we left off before the restart
+	jh.restore()
 
 	// We can launch the go-routing here as we tear-down in .Shutdown()
 	go jh.scheduler.Run()
 
-	return jh, err
+	// Polls, pushed events and job files all end up in the same scheduler
+	ctx, cancel := context.WithCancel(context.Background())
+	jh.stopSources = cancel
+
+	if push != nil {
+		jh.listen(ctx, push)
+	}
+
+	// Time critical jobs wait until the system time was confirmed
+	if !jh.timeConfirmed.Load() {
+		go jh.waitForTimeSync(ctx)
+	}
+
+	// Problems with upcoming jobs are reported ahead of their start
+	go jh.runPreflights(ctx)
+
+	// Sensors without uplink read their jobs from job files
+	if settings := app.Conf.Job().C().Spool; settings.Enabled {
+		jh.spool = backend.NewSpoolBackend(rest, settings, app.UsbManager)
+		go jh.spool.Run(ctx, jh.scheduleSpooled)
+	}
+
+	return jh, nil
 }
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+// How often the queue is searched for tasks within the pre-flight lead time
+const PreflightInterval = 15 * time.Second
+
+// jobWindow returns the length of a single run of the job
+func jobWindow(job api.FixedJob) time.Duration {
+	if job.Recurrence != nil {
+		return time.Duration(job.Recurrence.DurationSeconds) * time.Second
+	}
+
+	return job.EndTime.Sub(job.StartTime)
+}
+
+// preflight runs the checks the job type declares, the failed ones carry their result code
+func (h *TaskHandler) preflight(params *schema.JobParameters, window time.Duration) ([]api.CheckResult, bool) {
+	checks := h.backend.GetPreflightChecks(params)
+	if len(checks) == 0 {
+		return nil, true
+	}
+
+	env := preflight.Env{
+		App:      h.app,
+		Job:      params.Job.(api.FixedJob),
+		Config:   params.Config,
+		Duration: window,
+		Local:    len(params.Spool) != 0,
+	}
+
+	results, ok := preflight.Run(checks, env)
+	for i := range results {
+		if results[i].Status == preflight.StatusFailed {
+			results[i].Code = string(result.CodePreflightFailed)
+		}
+	}
+
+	return results, ok
+}
+
+// withPreflight runs the checks again right before the job function, a failed check ends the attempt.
+// The conditions might clear up, so the attempt is retried like any other failed one
+func (h *TaskHandler) withPreflight(params *schema.JobParameters, window time.Duration, fn scheduler.JobFunction) scheduler.JobFunction {
+	if len(h.backend.GetPreflightChecks(params)) == 0 {
+		return fn
+	}
+
+	job := params.Job.(api.FixedJob)
+	return func(ctx context.Context, arg interface{}) error {
+		results, _ := h.preflight(params, window)
+		failed, ok := preflight.FirstFailed(results)
+		if !ok {
+			return fn(ctx, arg)
+		}
+
+		err := preflight.Failure(results)
+		log.Error("job failed its pre-flight checks", zap.String("job", job.Json()), zap.Error(err))
+
+		status, details := "failed", failed.Name+":"+failed.Message
+		if scheduler.WillRetry(ctx, err) {
+			attempt, maxAttempts := scheduler.Attempt(ctx)
+			status, details = "retrying", fmt.Sprintf("%d/%d:%s", attempt, maxAttempts, details)
+		}
+		h.report(params.Uplink(), job, status, result.CodePreflightFailed, details)
+
+		return err
+	}
+}
+
+// runPreflights checks the tasks that start within the lead time until ctx is done
+func (h *TaskHandler) runPreflights(ctx context.Context) {
+	timer := h.clock.NewTimer(PreflightInterval)
+	defer timer.Stop()
+
+	checked := make(map[string]time.Time)
+	for {
+		checked = h.checkUpcoming(checked)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			timer.Reset(PreflightInterval)
+		}
+	}
+}
+
+// checkUpcoming runs the checks of every upcoming task once, a failure is reported as a job update right away.
+// The tasks stay queued, they are checked again when they start. Returns the start times of the checked tasks
+func (h *TaskHandler) checkUpcoming(checked map[string]time.Time) map[string]time.Time {
+	upcoming := h.scheduler.Upcoming(h.app.Conf.Job().C().Preflight.Lead())
+
+	next := make(map[string]time.Time, len(upcoming))
+	for _, task := range upcoming {
+		// Rescheduled tasks are checked again
+		if start, ok := checked[task.ID]; ok && start.Equal(task.StartTime) {
+			next[task.ID] = start
+			continue
+		}
+		next[task.ID] = task.StartTime
+
+		params, ok := task.Argument.(*schema.JobParameters)
+		if !ok {
+			continue
+		}
+
+		results, _ := h.preflight(params, task.EndTime.Sub(task.StartTime))
+		failed, ok := preflight.FirstFailed(results)
+		if !ok {
+			continue
+		}
+
+		job := params.Job.(api.FixedJob)
+		log.Warn("upcoming job fails its pre-flight checks", zap.String("id", task.ID), zap.Time("start", task.StartTime), zap.String("check", failed.Name), zap.String("reason", failed.Message))
+
+		verb := job.StepStatus("preflight_failed(" + strings.ReplaceAll(failed.Name+":"+failed.Message, " ", "_") + ")")
+		sink := params.Uplink()
+		go func() {
+			if err := sink.PutJobUpdate(job, verb); err != nil {
+				log.Error("could not report failed pre-flight checks", zap.String("id", job.Id), zap.Error(err))
+			}
+		}()
+	}
+
+	return next
+}
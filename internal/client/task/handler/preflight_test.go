@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/clock"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBackend runs a single job function and declares a check that fails until it is cleared
+type fakeBackend struct {
+	failing atomic.Bool
+	fn      scheduler.JobFunction
+}
+
+func (b *fakeBackend) GetJobHandlerFromParameters(*schema.JobParameters) (scheduler.JobFunction, scheduler.ResourceClaims) {
+	return b.fn, nil
+}
+
+func (b *fakeBackend) ValidateJob(*schema.JobParameters) error {
+	return nil
+}
+
+func (b *fakeBackend) GetPreflightChecks(*schema.JobParameters) []preflight.Check {
+	return []preflight.Check{{Name: "sdr", Run: func(preflight.Env) error {
+		if b.failing.Load() {
+			return preflight.ErrNoSDR
+		}
+		return nil
+	}}}
+}
+
+func TestJobWindow(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Hour, jobWindow(api.FixedJob{StartTime: start, EndTime: start.Add(time.Hour)}))
+
+	// Recurring jobs run for the duration of an occurrence, not until the recurrence ends
+	recurring := api.FixedJob{StartTime: start, EndTime: start.Add(24 * time.Hour), Recurrence: &api.Recurrence{DurationSeconds: 600}}
+	assert.Equal(t, 10*time.Minute, jobWindow(recurring))
+}
+
+func TestWithPreflight(t *testing.T) {
+	log.Init(true)
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, server := newTestHandler(t, now)
+
+	var runs atomic.Int32
+	b := &fakeBackend{fn: func(context.Context, interface{}) error {
+		runs.Add(1)
+		return nil
+	}}
+	b.failing.Store(true)
+	h.backend = b
+
+	job := api.FixedJob{Id: "42", Name: "capture", Command: "capture", StartTime: now, EndTime: now.Add(time.Hour)}
+	params := &schema.JobParameters{Job: job, App: h.app, Config: h.app.Conf.Job().C()}
+	fn := h.withPreflight(params, jobWindow(job), b.fn)
+
+	// A failed check ends the attempt before the job function runs
+	err := fn(context.Background(), params)
+	assert.ErrorIs(t, err, preflight.ErrFailed)
+	assert.Zero(t, runs.Load())
+	assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, 10*time.Millisecond)
+
+	failed := server.received()[0]
+	assert.Equal(t, "failed(sdr:no_SDR_attached)", failed.Status)
+	assert.Equal(t, "42", failed.Result.JobID)
+	assert.Equal(t, string(result.CodePreflightFailed), failed.Result.Code)
+	assert.Equal(t, "sdr:no SDR attached", failed.Result.Message)
+
+	// Once the checks pass the job runs and reports itself
+	b.failing.Store(false)
+	assert.NoError(t, fn(context.Background(), params))
+	assert.Equal(t, int32(1), runs.Load())
+	assert.Never(t, func() bool { return len(server.received()) != 1 }, 100*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestWithPreflightRetry(t *testing.T) {
+	log.Init(true)
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, server := newTestHandler(t, now)
+	clk := h.clock.(*clock.Fake)
+
+	b := &fakeBackend{fn: func(context.Context, interface{}) error { return nil }}
+	b.failing.Store(true)
+	h.backend = b
+
+	// The failed check is retried within the window like any other failed attempt
+	job := api.FixedJob{Id: "42", Name: "capture", Command: "capture", StartTime: now, EndTime: now.Add(time.Hour), Retry: &api.RetryOptions{MaxAttempts: 2, BackoffSeconds: 60}}
+	task, err := h.newTask(&schema.JobParameters{Job: job, App: h.app, Config: h.app.Conf.Job().C()})
+	assert.NoError(t, err)
+	assert.NoError(t, h.scheduler.Schedule(task))
+	assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, 10*time.Millisecond)
+
+	retrying := server.received()[0]
+	assert.Equal(t, "retrying(1/2:sdr:no_SDR_attached)", retrying.Status)
+	assert.Equal(t, string(result.CodePreflightFailed), retrying.Result.Code)
+
+	// The last attempt fails for good
+	assert.Eventually(t, func() bool {
+		clk.Advance(10 * time.Second)
+		return len(server.received()) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "failed(sdr:no_SDR_attached)", server.received()[1].Status)
+}
+
+func TestCheckUpcoming(t *testing.T) {
+	log.Init(true)
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	h, server := newTestHandler(t, now)
+
+	b := &fakeBackend{fn: func(context.Context, interface{}) error { return nil }}
+	b.failing.Store(true)
+	h.backend = b
+
+	// Within the lead time, the later one is not checked yet
+	soon := api.FixedJob{Id: "42", Name: "soon", Command: "capture", StartTime: now.Add(time.Minute), EndTime: now.Add(time.Hour)}
+	later := api.FixedJob{Id: "43", Name: "later", Command: "capture", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+	for _, job := range []api.FixedJob{soon, later} {
+		task, err := h.newTask(&schema.JobParameters{Job: job, App: h.app, Config: h.app.Conf.Job().C()})
+		assert.NoError(t, err)
+		assert.NoError(t, h.scheduler.Schedule(task))
+	}
+
+	checked := h.checkUpcoming(nil)
+	assert.Equal(t, map[string]time.Time{"42": soon.StartTime}, checked)
+	assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, 10*time.Millisecond)
+
+	// The failure is an update, not a result
+	update := server.received()[0]
+	assert.Equal(t, "preflight_failed(sdr:no_SDR_attached)", update.Status)
+	assert.Empty(t, update.Result.JobID)
+
+	// Checked tasks are not reported again and stay queued
+	assert.Equal(t, checked, h.checkUpcoming(checked))
+	assert.Never(t, func() bool { return len(server.received()) != 1 }, 100*time.Millisecond, 10*time.Millisecond)
+	assert.Len(t, h.scheduler.Upcoming(2*time.Hour), 2)
+}
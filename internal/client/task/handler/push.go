@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs"
+	"github.com/LeoCommon/client/internal/client/task/jobs/backend"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+// listen passes the pushed events to the handler until Shutdown, Tick only polls while the stream is down
+func (h *TaskHandler) listen(ctx context.Context, push backend.Push) {
+	go push.Run(ctx, h.onEvent)
+}
+
+// onEvent handles an event of the job stream
+func (h *TaskHandler) onEvent(e api.JobEvent) {
+	switch e.Type {
+	case api.JobEventList:
+		h.sync(e.Jobs)
+	case api.JobEventJob:
+		h.syncing.Lock()
+		defer h.syncing.Unlock()
+
+		if jobCancelled(*e.Job, h.app.Conf.SensorName()) {
+			h.cancelByServer(e.Job.Id)
+			return
+		}
+		h.scheduleJobs([]api.FixedJob{*e.Job})
+	case api.JobEventCancel:
+		h.syncing.Lock()
+		defer h.syncing.Unlock()
+
+		h.cancelByServer(e.JobID)
+	case api.JobEventConfig:
+		h.configure(e.Config)
+	}
+}
+
+// configure applies pushed configuration changes, they are checked like the arguments of a set_sys_config job
+func (h *TaskHandler) configure(settings map[string]string) {
+	// Pushed settings carry no signature, they have to arrive as signed job
+	if h.app.Conf.Api().C().Signing.Required(jobs.SetConfigCommand) {
+		log.Error("rejected pushed configuration, it needs a signed set_sys_config job", zap.Any("settings", settings))
+		return
+	}
+
+	params := &schema.JobParameters{
+		Job:    api.FixedJob{Command: jobs.SetConfigCommand, Arguments: settings},
+		App:    h.app,
+		Config: h.app.Conf.Job().C(),
+	}
+	if err := h.backend.ValidateJob(params); err != nil {
+		log.Error("rejected pushed configuration", zap.Any("settings", settings), zap.Error(err))
+		return
+	}
+
+	if err := jobs.ApplyConfig(h.app.Conf, settings); err != nil {
+		log.Error("could not apply pushed configuration", zap.Any("settings", settings), zap.Error(err))
+		return
+	}
+
+	log.Info("applied pushed configuration", zap.Any("settings", settings))
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+// isCancelled matches the job states the server uses for cancelled jobs
+func isCancelled(status string) bool {
+	return strings.HasPrefix(status, "cancelled") || strings.HasPrefix(status, "canceled")
+}
+
+// jobCancelled returns true if the server cancelled the job as a whole or for this sensor
+func jobCancelled(job api.FixedJob, sensor string) bool {
+	return isCancelled(job.Status) || isCancelled(job.States[sensor])
+}
+
+// belongsTo returns true if the task executes the job, either directly,
+// as a step of the composite job or as an occurrence of the recurring job
+func belongsTo(taskID string, jobID string) bool {
+	return taskID == jobID ||
+		strings.HasPrefix(taskID, jobID+"/") ||
+		strings.HasPrefix(taskID, jobID+"@")
+}
+
+// track remembers a job that has tasks in the scheduler, composite jobs are tracked as a whole
+func (h *TaskHandler) track(job api.FixedJob) {
+	job.Step = ""
+
+	h.Lock()
+	defer h.Unlock()
+	h.jobs[job.Id] = job
+}
+
+// isTracked returns true if the server job has tasks in the scheduler
+func (h *TaskHandler) isTracked(id string) bool {
+	h.RLock()
+	defer h.RUnlock()
+
+	_, ok := h.jobs[id]
+	return ok
+}
+
+// reconcile cancels the tasks of the jobs the server deleted or cancelled since they were scheduled,
+// polled has to be the complete job list of the sensor
+func (h *TaskHandler) reconcile(polled []api.FixedJob) {
+	sensor := h.app.Conf.SensorName()
+	listed := make(map[string]api.FixedJob, len(polled))
+	for _, job := range polled {
+		listed[job.Id] = job
+	}
+
+	snap := h.scheduler.Snapshot()
+	tasks := slices.Concat(snap.Queued, snap.Running, snap.Recurring)
+
+	h.Lock()
+	defer h.Unlock()
+
+	for id, job := range h.jobs {
+		if !hasTasks(tasks, id) {
+			// The job ended, nothing to reconcile anymore
+			delete(h.jobs, id)
+			continue
+		}
+
+		details := ""
+		if current, ok := listed[id]; !ok {
+			details = "deleted"
+		} else if !jobCancelled(current, sensor) {
+			continue
+		}
+
+		h.cancelTracked(job, details, snap.Running)
+	}
+}
+
+// cancelByServer cancels the tasks of the job the server cancelled and acknowledges it
+func (h *TaskHandler) cancelByServer(id string) {
+	snap := h.scheduler.Snapshot()
+
+	h.Lock()
+	defer h.Unlock()
+
+	job, ok := h.jobs[id]
+	if !ok {
+		log.Debug("cancelled job has no tasks", zap.String("id", id))
+		return
+	}
+
+	h.cancelTracked(job, "", snap.Running)
+}
+
+// cancelTracked cancels the tasks of a tracked job and acknowledges the cancellation, must be called with the lock held
+func (h *TaskHandler) cancelTracked(job api.FixedJob, details string, running []scheduler.TaskInfo) {
+	log.Info("job was cancelled by the server", zap.String("id", job.Id), zap.String("name", job.Name), zap.Bool("deleted", details == "deleted"))
+	delete(h.jobs, job.Id)
+	h.cancelTasks(job)
+
+	// Running tasks acknowledge the cancellation with their own result
+	if !hasTasks(running, job.Id) {
+		h.report(h.app.Api, job, "cancelled", result.CodeCanceled, details)
+	}
+}
+
+// hasTasks returns true if any of the tasks belongs to the job
+func hasTasks(tasks []scheduler.TaskInfo, jobID string) bool {
+	return slices.ContainsFunc(tasks, func(info scheduler.TaskInfo) bool { return belongsTo(info.ID, jobID) })
+}
+
+// cancelTasks cancels all tasks of the job, the steps of composite jobs are cancelled
+// last to first so no step is skipped because its dependency was cancelled first.
+// Returns false if none of the tasks was left to cancel
+func (h *TaskHandler) cancelTasks(job api.FixedJob) bool {
+	cancelled := h.scheduler.Cancel(job.Id)
+
+	for i := len(job.Steps) - 1; i >= 0; i-- {
+		if h.scheduler.Cancel(stepID(job, job.Steps[i].Name)) {
+			cancelled = true
+		}
+	}
+
+	return cancelled
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBelongsTo(t *testing.T) {
+	job := api.FixedJob{Id: "42"}
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, belongsTo("42", job.Id))
+	assert.True(t, belongsTo(stepID(job, "capture"), job.Id))
+	assert.True(t, belongsTo(scheduler.OccurrenceID(job.Id, start), job.Id))
+
+	// Other jobs that only share the prefix
+	assert.False(t, belongsTo("421", job.Id))
+	assert.False(t, belongsTo("4", job.Id))
+}
+
+func TestJobCancelled(t *testing.T) {
+	assert.False(t, jobCancelled(api.FixedJob{Status: "pending", States: map[string]string{"sensor": "pending"}}, "sensor"))
+	assert.True(t, jobCancelled(api.FixedJob{Status: "cancelled"}, "sensor"))
+	assert.True(t, jobCancelled(api.FixedJob{Status: "canceled"}, "sensor"))
+
+	// Cancelled for a single sensor
+	job := api.FixedJob{Status: "pending", States: map[string]string{"sensor": "cancelled", "other": "pending"}}
+	assert.True(t, jobCancelled(job, "sensor"))
+	assert.False(t, jobCancelled(job, "other"))
+}
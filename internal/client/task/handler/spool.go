@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/jobs/spool"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+// scheduleSpooled schedules a job file of the spool directory dir, it reports to sink instead of the server.
+// Job files are checked against the signing policy like server jobs, USB drives are no more trusted than the server
+func (h *TaskHandler) scheduleSpooled(job api.FixedJob, dir string, sink api.JobSink) error {
+	if job.DryRun {
+		h.reportDryRun(sink, &schema.JobParameters{Job: job, App: h.app, Config: h.app.Conf.Job().C(), Spool: dir, Sink: sink})
+		return nil
+	}
+
+	verified, err := h.verifier.Verify(job, h.clock.Now())
+	if err != nil {
+		h.rejectSignature(sink, job, err)
+		return err
+	}
+
+	return h.scheduleLocal(verified, dir, sink)
+}
+
+// scheduleLocal schedules a job the server does not know, it reports to sink
+func (h *TaskHandler) scheduleLocal(job api.FixedJob, dir string, sink api.JobSink) error {
+	params := &schema.JobParameters{
+		Job:    job,
+		App:    h.app,
+		Config: h.app.Conf.Job().C(),
+		Spool:  dir,
+		Sink:   sink,
+	}
+
+	h.syncing.Lock()
+	defer h.syncing.Unlock()
+
+	err := h.admit(params)
+	if err == scheduler.ErrTaskAlreadyExists || err == scheduler.ErrTaskAlreadyRunning {
+		log.Warn("spooled job is already scheduled", zap.String("id", job.Id), zap.String("dir", dir))
+		return nil
+	}
+	if err == nil {
+		h.trackSpooled(job, sink)
+	}
+
+	return err
+}
+
+// spooledJob is a job the server does not know, its updates go to the sink
+type spooledJob struct {
+	job  api.FixedJob
+	sink api.JobSink
+}
+
+// trackSpooled remembers a spooled job so it can be cancelled locally
+func (h *TaskHandler) trackSpooled(job api.FixedJob, sink api.JobSink) {
+	job.Step = ""
+
+	h.Lock()
+	defer h.Unlock()
+	h.spooled[job.Id] = spooledJob{job: job, sink: sink}
+}
+
+// attachSink restores the sink of a journaled spool job, the local spool directory takes over if the drive is gone
+func (h *TaskHandler) attachSink(params *schema.JobParameters) {
+	if len(params.Spool) == 0 {
+		return
+	}
+
+	params.Sink = spool.NewOutbox(params.Spool, h.app.Conf.Job().C().Spool.Directory())
+}
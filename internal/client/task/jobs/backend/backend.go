@@ -1,11 +1,16 @@
 package backend
 
 import (
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
 	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
 	"github.com/LeoCommon/client/internal/client/task/scheduler"
 )
 
 type Backend interface {
 	// Sets up required run time parameters
-	GetJobHandlerFromParameters(*schema.JobParameters) (scheduler.JobFunction, scheduler.ExclusiveResources)
+	GetJobHandlerFromParameters(*schema.JobParameters) (scheduler.JobFunction, scheduler.ResourceClaims)
+	// Checks the job arguments before the job is scheduled, returns a *registry.ValidationError with all violations
+	ValidateJob(*schema.JobParameters) error
+	// Returns the checks that have to pass before the job starts
+	GetPreflightChecks(*schema.JobParameters) []preflight.Check
 }
@@ -0,0 +1,99 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+const (
+	// Reconnect delays of a broken job stream, doubled after every failed attempt
+	MinReconnectDelay = 5 * time.Second
+	MaxReconnectDelay = 5 * time.Minute
+)
+
+// Push is implemented by backends that deliver jobs without waiting for the next poll
+type Push interface {
+	Backend
+	// Connected reports if jobs are pushed, they only have to be polled otherwise
+	Connected() bool
+	// Run passes the pushed events to fn until ctx is done, a broken stream is reconnected
+	Run(ctx context.Context, fn func(api.JobEvent))
+}
+
+// JobStream is the connection to the server the pushed jobs arrive on, see api.RestAPI.StreamJobs
+type JobStream interface {
+	StreamJobs(ctx context.Context, lastEventID string, fn func(api.JobEvent)) error
+}
+
+// pushBackend receives the jobs over a server-sent event stream, they are executed like polled jobs
+type pushBackend struct {
+	Backend
+	stream    JobStream
+	connected atomic.Bool
+
+	// Resumes the stream after a reconnect
+	lastEventID string
+
+	minDelay time.Duration
+	maxDelay time.Duration
+}
+
+// Connected implements Push
+func (b *pushBackend) Connected() bool {
+	return b.connected.Load()
+}
+
+// Run implements Push
+func (b *pushBackend) Run(ctx context.Context, fn func(api.JobEvent)) {
+	delay := b.minDelay
+
+	for {
+		err := b.stream.StreamJobs(ctx, b.lastEventID, func(e api.JobEvent) {
+			if !b.connected.Swap(true) {
+				log.Info("job stream connected, jobs are pushed")
+			}
+			delay = b.minDelay
+
+			if len(e.ID) != 0 {
+				b.lastEventID = e.ID
+			}
+			fn(e)
+		})
+		b.connected.Store(false)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Servers without the stream will not get it anytime soon
+		if errors.Is(err, api.ErrStreamUnsupported) {
+			delay = b.maxDelay
+		}
+		log.Warn("job stream unavailable, polling until it is back", zap.Error(err), zap.Duration("retryIn", delay))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(2*delay, b.maxDelay)
+	}
+}
+
+// NewPushBackend returns a backend that receives the jobs over the stream and executes them with the given backend
+func NewPushBackend(executor Backend, stream JobStream) Push {
+	return &pushBackend{
+		Backend:  executor,
+		stream:   stream,
+		minDelay: MinReconnectDelay,
+		maxDelay: MaxReconnectDelay,
+	}
+}
@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushBackend(t *testing.T) {
+	log.Init(true)
+
+	// The first connection fails, the second one pushes a job and breaks, the third one stays open
+	var connections atomic.Int32
+	lastIDs := make(chan string, 3)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs <- r.Header.Get("Last-Event-ID")
+		switch connections.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case 2:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "id: 1\nevent: jobs\ndata: [{\"id\":\"42\"}]\n\n")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 2\nevent: cancel\ndata: {\"id\":\"42\"}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	assert.NoError(t, os.WriteFile(path, []byte("[client]\nsensor_name = 'sensor'\n[api]\nurl = '"+server.URL+"/'\n"), 0644))
+	conf := config.NewManager()
+	assert.NoError(t, conf.Load(path, false))
+	restAPI, err := api.NewRestAPI(conf, false)
+	assert.NoError(t, err)
+
+	rest, err := NewRestAPIBackend(restAPI)
+	assert.NoError(t, err)
+	b := NewPushBackend(rest, restAPI)
+	b.(*pushBackend).minDelay = time.Millisecond
+	assert.False(t, b.Connected())
+
+	events := make(chan api.JobEvent, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx, func(e api.JobEvent) { events <- e })
+		close(done)
+	}()
+
+	e := <-events
+	assert.Equal(t, api.JobEventList, e.Type)
+	assert.Equal(t, "42", e.Jobs[0].Id)
+	e = <-events
+	assert.Equal(t, api.JobEventCancel, e.Type)
+	assert.True(t, b.Connected())
+
+	// Reconnects resume after the last event
+	assert.Equal(t, "", <-lastIDs)
+	assert.Equal(t, "", <-lastIDs)
+	assert.Equal(t, "1", <-lastIDs)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("push backend did not stop")
+	}
+	assert.False(t, b.Connected())
+}
@@ -2,117 +2,170 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/LeoCommon/client/internal/client/api"
 	"github.com/LeoCommon/client/internal/client/task/jobs"
 	"github.com/LeoCommon/client/internal/client/task/jobs/iridium"
 	"github.com/LeoCommon/client/internal/client/task/jobs/network"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
 	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
 	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/clock"
 	"github.com/LeoCommon/client/pkg/log"
-	"github.com/LeoCommon/client/pkg/system/services/net"
 
 	"go.uber.org/zap"
 )
 
 type restAPIBackend struct {
-	api *api.RestAPI
+	api      *api.RestAPI
+	registry *registry.Registry
 }
 
 // GetJobHandlerFromParameters implements Backend
-func (h *restAPIBackend) GetJobHandlerFromParameters(jp *schema.JobParameters) (scheduler.JobFunction, scheduler.ExclusiveResources) {
+func (h *restAPIBackend) GetJobHandlerFromParameters(jp *schema.JobParameters) (scheduler.JobFunction, scheduler.ResourceClaims) {
 	if fj, ok := jp.Job.(api.FixedJob); ok {
-		resources := scheduler.ExclusiveResources{}
+		reg, ok := h.registry.Lookup(fj.Command)
+		if !ok {
+			log.Error("unsupported job command", zap.String("name", fj.Name), zap.String("command", fj.Command))
+			return nil, scheduler.ResourceClaims{}
+		}
 
-		// fixme: this should ideally be set on the server, or stored in a static mapping somewhere
-		// If the job contains the word iridium, we need the SDR in exclusive mode
-		if strings.Contains(strings.ToLower(fj.Command), "iridium") {
-			resources = append(resources, scheduler.SDRDevice1)
+		handler := func(ctx context.Context, param interface{}) error {
+			return h.handleFixedJob(ctx, reg, param)
 		}
 
-		return h.handleFixedJob, resources
+		return handler, slices.Clone(reg.Resources)
 	}
 
 	log.Error("unsupported job type passed to the rest api backend", zap.Any("type", jp.Job))
-	return nil, scheduler.ExclusiveResources{}
+	return nil, scheduler.ResourceClaims{}
+}
+
+// ValidateJob implements Backend
+func (h *restAPIBackend) ValidateJob(jp *schema.JobParameters) error {
+	fj, ok := jp.Job.(api.FixedJob)
+	if !ok {
+		return nil
+	}
+
+	// Unknown commands are reported when the handler is looked up
+	reg, ok := h.registry.Lookup(fj.Command)
+	if !ok {
+		return nil
+	}
+
+	return reg.Validate(fj.Arguments)
+}
+
+// GetPreflightChecks implements Backend
+func (h *restAPIBackend) GetPreflightChecks(jp *schema.JobParameters) []preflight.Check {
+	fj, ok := jp.Job.(api.FixedJob)
+	if !ok {
+		return nil
+	}
+
+	reg, ok := h.registry.Lookup(fj.Command)
+	if !ok {
+		return nil
+	}
+
+	return reg.Preflight
 }
 
 // This is a dynamic task selection because we need to be able to run POST Hooks
-func (b *restAPIBackend) handleFixedJob(ctx context.Context, param interface{}) error {
+func (b *restAPIBackend) handleFixedJob(ctx context.Context, reg registry.Registration, param interface{}) error {
 	jp := param.(*schema.JobParameters)
 
 	apiJob := jp.Job.(api.FixedJob)
-	cmd := strings.ToLower(apiJob.Command)
 	jobName := apiJob.Name
-	//jobId := apiJob.Id
 
-	log.Info("Job starting", zap.String("name", jobName), zap.String("command", cmd), zap.Time("startTime", apiJob.StartTime), zap.Time("endTime", apiJob.EndTime))
+	log.Info("Job starting", zap.String("id", apiJob.Id), zap.String("name", jobName), zap.String("command", reg.Command), zap.Time("startTime", apiJob.StartTime), zap.Time("endTime", apiJob.EndTime))
 
-	//runningErr := b.api.PutJobUpdate(jobId, "running")
-	runningErr := b.api.PutJobUpdate(jobName, "running")
+	// Jobs from the spool directory report to its outbox instead of the server
+	uplink := jp.Uplink()
+	runningErr := uplink.PutJobUpdate(apiJob, apiJob.StepStatus("running"))
 	if runningErr != nil {
 		// if an error occurs here, do not continue. Something big is broken, this should always work.
-		log.Error("push Job starting", zap.String("name", jobName), zap.NamedError("runningError", runningErr))
+		log.Error("push Job starting", zap.String("id", apiJob.Id), zap.String("name", jobName), zap.NamedError("runningError", runningErr))
 		return runningErr
 	}
 
-	var err error
-	if strings.Contains("get_status", cmd) {
-		err = jobs.PushStatus(jp)
-	} else if strings.Contains("get_full_status", cmd) {
-		err = jobs.ReportFullStatus(ctx, apiJob, jp)
-	} else if strings.Contains("iridium_sniffing", cmd) {
-		err = iridium.IridiumSniffing(ctx, apiJob, jp)
-	} else if strings.Contains("get_logs", cmd) {
-		err = jobs.GetLogs(ctx, apiJob, jp)
-	} else if strings.Contains("reboot", cmd) {
-		err = jobs.RebootSensor(apiJob, jp)
-	} else if strings.Contains("reset", cmd) {
-		// send a 'job finished' message, assuming everything worked. (You have no other chance to mark it as finished.)
-		err = b.api.PutJobUpdate(jobName, "finished")
-		if err != nil {
-			log.Info("hasty push reset result 'finished'", zap.String("name", jobName), zap.NamedError("PutJobUpdate", err))
-		}
-		err = jobs.ForceReset()
-	} else if strings.Contains("set_network_conn", cmd) {
-		err = network.SetNetworkConnectivity(apiJob, jp)
-	} else if strings.Contains("set_wifi_config", cmd) {
-		err = network.SetConfig(apiJob, jp, net.WiFi)
-	} else if strings.Contains("set_eth_config", cmd) {
-		err = network.SetConfig(apiJob, jp, net.Ethernet)
-	} else if strings.Contains("set_gsm_config", cmd) {
-		err = network.SetConfig(apiJob, jp, net.GSM)
-	} else if strings.Contains("get_sys_config", cmd) {
-		err = jobs.GetConfig(ctx, apiJob, jp)
-	} else if strings.Contains("set_sys_config", cmd) {
-		err = jobs.SetConfig(apiJob, jp)
-	} else {
-		err = fmt.Errorf("unsupported job was sent to the client")
+	// The timeout only bounds the handler, preemption and retries are decided on the task context
+	clk := jp.TimeSource()
+	runCtx := ctx
+	if reg.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = clock.WithTimeout(ctx, clk, reg.Timeout)
+		defer cancel()
 	}
 
-	verb := "finished"
-	if err != nil {
+	rec := result.NewRecorder()
+	err := reg.Handler(result.WithRecorder(runCtx, rec), apiJob, jp)
+
+	status := "finished"
+	verb := status
+	if errors.Is(context.Cause(ctx), scheduler.ErrTaskPreempted) {
+		// A job with a higher priority needed the resources
+		status = "preempted"
+		verb = status
+	} else if errors.Is(context.Cause(ctx), scheduler.ErrTaskCancelled) {
+		// The server deleted or cancelled the job, this acknowledges it
+		status = "cancelled"
+		verb = status
+	} else if errors.Is(context.Cause(ctx), scheduler.ErrSchedulerDraining) {
+		// The client shuts down and the job did not end within the drain timeout
+		status = "interrupted"
+		verb = "interrupted(draining)"
+	} else if err != nil {
 		errStr := strings.ReplaceAll(err.Error(), " ", "_")
+		status = "failed"
 		verb = "failed(" + errStr + ")"
+
+		// The scheduler starts the job again within its window
+		if scheduler.WillRetry(ctx, err) {
+			attempt, maxAttempts := scheduler.Attempt(ctx)
+			status = "retrying"
+			verb = fmt.Sprintf("retrying(%d/%d:%s)", attempt, maxAttempts, errStr)
+		}
 	}
 
-	//submitErr := b.api.PutJobUpdate(jobId, verb)
-	submitErr := b.api.PutJobUpdate(jobName, verb)
+	res := rec.Result(ctx, apiJob, status, err, clk.Now())
+	if cause := context.Cause(runCtx); cause != nil && status != "finished" {
+		// Cancelled jobs mostly return context.Canceled, the cause tells why
+		res.Code = string(result.Classify(cause))
+	}
+
+	verb = apiJob.StepStatus(verb)
+	submitErr := uplink.PutJobResult(apiJob, verb, res)
 	if submitErr != nil {
 		// if an error occurs here, do not continue. Something big is broken, this should always work.
-		log.Error("push Job result", zap.String("name", jobName), zap.String("status", verb), zap.NamedError("submitError", submitErr))
-		return submitErr
+		log.Error("push Job result", zap.String("id", apiJob.Id), zap.String("name", jobName), zap.String("status", verb), zap.NamedError("submitError", submitErr))
+		return errors.Join(err, submitErr)
 	}
-	log.Info("Job result change", zap.String("name", jobName), zap.NamedError("executionError", err), zap.String("finalState", verb))
+	log.Info("Job result change", zap.String("id", apiJob.Id), zap.String("name", jobName), zap.NamedError("executionError", err), zap.String("finalState", verb))
 
-	return nil
+	// The scheduler needs the job error to decide about retries and dependent steps
+	return err
 }
 
 func NewRestAPIBackend(api *api.RestAPI) (Backend, error) {
 	b := &restAPIBackend{
-		api,
+		api:      api,
+		registry: registry.New(),
+	}
+
+	registrations := append(jobs.Registrations(), iridium.Registration())
+	registrations = append(registrations, network.Registrations()...)
+	for _, reg := range registrations {
+		if err := b.registry.Register(reg); err != nil {
+			return nil, fmt.Errorf("registering job %q: %w", reg.Command, err)
+		}
 	}
 
 	return b, nil
@@ -0,0 +1,125 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/clock"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingSink keeps the final results of the jobs by status
+type recordingSink struct {
+	m       sync.Mutex
+	results map[string]api.JobResult
+}
+
+func (s *recordingSink) PutJobUpdate(api.FixedJob, string) error {
+	return nil
+}
+
+func (s *recordingSink) PutJobResult(_ api.FixedJob, status string, res api.JobResult) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.results[status] = res
+	return nil
+}
+
+func (s *recordingSink) PostSensorData(context.Context, string, string) error {
+	return nil
+}
+
+func TestHandleFixedJobCancelled(t *testing.T) {
+	log.Init(true)
+
+	b := &restAPIBackend{registry: registry.New()}
+	reg := registry.Registration{Command: "capture", Handler: func(ctx context.Context, _ api.FixedJob, _ *schema.JobParameters) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	// The cause of the cancellation decides the status, not the context error the job returns
+	for cause, want := range map[error]struct {
+		verb string
+		code result.Code
+	}{
+		scheduler.ErrTaskPreempted:     {"preempted", result.CodePreempted},
+		scheduler.ErrTaskCancelled:     {"cancelled", result.CodeCanceled},
+		scheduler.ErrSchedulerDraining: {"interrupted(draining)", result.CodeDraining},
+	} {
+		sink := &recordingSink{results: make(map[string]api.JobResult)}
+		jp := &schema.JobParameters{Job: api.FixedJob{Id: "42", Name: "capture", Command: "capture", EndTime: time.Now().Add(time.Hour)}, Sink: sink}
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(cause)
+		assert.ErrorIs(t, b.handleFixedJob(ctx, reg, jp), context.Canceled)
+
+		if assert.Contains(t, sink.results, want.verb, cause.Error()) {
+			assert.Equal(t, string(want.code), sink.results[want.verb].Code)
+		}
+	}
+}
+
+func TestHandleFixedJobTimeout(t *testing.T) {
+	log.Init(true)
+
+	b := &restAPIBackend{registry: registry.New()}
+	reg := registry.Registration{Command: "capture", Timeout: time.Minute, Handler: func(ctx context.Context, _ api.FixedJob, _ *schema.JobParameters) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	// The timeout and the result time are measured by the clock of the job parameters
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+	sink := &recordingSink{results: make(map[string]api.JobResult)}
+	jp := &schema.JobParameters{Job: api.FixedJob{Id: "42", Name: "capture", Command: "capture", EndTime: now.Add(time.Hour)}, Sink: sink, Clock: clk}
+
+	done := make(chan error, 1)
+	go func() { done <- b.handleFixedJob(context.Background(), reg, jp) }()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+	assert.ErrorIs(t, <-done, context.DeadlineExceeded)
+
+	verb := "failed(context_deadline_exceeded)"
+	if assert.Contains(t, sink.results, verb) {
+		assert.Equal(t, string(result.CodeTimeout), sink.results[verb].Code)
+		assert.Equal(t, now.Add(time.Minute).UnixMilli(), sink.results[verb].FinishedAt)
+	}
+}
+
+func TestRegistrationResources(t *testing.T) {
+	log.Init(true)
+
+	b, err := NewRestAPIBackend(nil)
+	assert.NoError(t, err)
+
+	start := time.Now().Add(time.Hour)
+	task := func(id string, command string) *scheduler.Task {
+		fn, claims := b.GetJobHandlerFromParameters(&schema.JobParameters{Job: api.FixedJob{Id: id, Command: command}})
+		assert.NotNil(t, fn)
+		return scheduler.NewTask(start, start.Add(time.Hour), fn, nil).WithID(id).WithResource(claims...)
+	}
+
+	s := scheduler.NewScheduler(2)
+	defer s.Shutdown()
+	go s.Run()
+
+	// A capture and the uploads coexist, the uploads share their resources
+	assert.NoError(t, s.Schedule(task("capture", "iridium_sniffing")))
+	assert.NoError(t, s.Schedule(task("logs", "get_logs")))
+	assert.NoError(t, s.Schedule(task("status", "get_full_status")))
+
+	// Two captures still conflict
+	assert.ErrorIs(t, s.Schedule(task("capture2", "iridium_sniffing")), scheduler.ErrResourceSharingNotPossible)
+}
@@ -0,0 +1,188 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/internal/client/task/jobs/spool"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/LeoCommon/client/pkg/usb"
+)
+
+// The spool directories are checked for new job files this often
+const SpoolScanInterval = 10 * time.Second
+
+// SpoolFunc schedules a job of the spool directory dir, the job reports to sink. The job file is rejected if it returns an error
+type SpoolFunc func(job api.FixedJob, dir string, sink api.JobSink) error
+
+// Spool is implemented by backends that read jobs from local job files instead of the server
+type Spool interface {
+	Backend
+	// Run passes the job files of the spool directories to fn until ctx is done
+	Run(ctx context.Context, fn SpoolFunc)
+}
+
+// spoolBackend reads the jobs from a local directory and the USB drives, they are executed like server jobs
+type spoolBackend struct {
+	Backend
+	// Always watched, also receives the results once a USB drive is gone
+	local string
+
+	// Optional, announces USB drives
+	usb      *usb.USBDeviceManager
+	mediaDir string
+	// Mount points of the attached USB drives by device
+	mounted map[string]string
+
+	interval time.Duration
+}
+
+type storageEvent struct {
+	dev   usb.StorageDevice
+	added bool
+}
+
+// Run implements Spool
+func (b *spoolBackend) Run(ctx context.Context, fn SpoolFunc) {
+	if err := os.MkdirAll(filepath.Join(b.local, spool.JobsFolder), 0750); err != nil {
+		log.Error("could not create the spool directory", zap.String("dir", b.local), zap.Error(err))
+	}
+
+	storage := make(chan storageEvent, 8)
+	if b.usb != nil {
+		b.usb.OnStorage(func(dev usb.StorageDevice, added bool) {
+			select {
+			case storage <- storageEvent{dev, added}:
+			default:
+				log.Warn("dropping usb storage event, spool is busy", zap.String("device", dev.DevName))
+			}
+		})
+
+		// Drives attached before the client started
+		for _, dev := range usb.AttachedStorage() {
+			b.attach(dev)
+		}
+	}
+	defer b.detachAll()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		b.scan(fn)
+
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-storage:
+			if e.added {
+				b.attach(e.dev)
+			} else {
+				b.detach(e.dev)
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// dirs returns the spool directories that are watched
+func (b *spoolBackend) dirs() []string {
+	dirs := []string{b.local}
+	for _, mountPoint := range b.mounted {
+		dirs = append(dirs, filepath.Join(mountPoint, spool.MediaFolder))
+	}
+
+	return dirs
+}
+
+// scan passes the new job files to fn and settles them
+func (b *spoolBackend) scan(fn SpoolFunc) {
+	for _, dir := range b.dirs() {
+		files, err := spool.Scan(dir)
+		if err != nil {
+			log.Error("could not read spool directory", zap.String("dir", dir), zap.Error(err))
+			continue
+		}
+
+		sink := spool.NewOutbox(dir, b.local)
+		for _, f := range files {
+			err := f.Err
+			if err == nil {
+				err = fn(f.Job, dir, sink)
+			}
+
+			if err != nil {
+				log.Error("rejected spooled job", zap.String("file", f.Path), zap.Error(err))
+			}
+			if serr := spool.Settle(f, err); serr != nil {
+				log.Error("could not move the spooled job file", zap.String("file", f.Path), zap.Error(serr))
+			}
+		}
+	}
+}
+
+// attach mounts a USB drive, it is only kept mounted if it carries a spool directory
+func (b *spoolBackend) attach(dev usb.StorageDevice) {
+	if _, ok := b.mounted[dev.DevName]; ok {
+		return
+	}
+
+	mountPoint := filepath.Join(b.mediaDir, filepath.Base(dev.DevName))
+	if err := dev.Mount(mountPoint); err != nil {
+		log.Error("could not mount usb drive", zap.String("device", dev.DevName), zap.String("fs", dev.FSType), zap.Error(err))
+		return
+	}
+
+	if _, err := os.Stat(filepath.Join(mountPoint, spool.MediaFolder, spool.JobsFolder)); err != nil {
+		log.Info("usb drive has no spool directory", zap.String("device", dev.DevName), zap.String("folder", spool.MediaFolder))
+		_ = usb.Unmount(mountPoint)
+		return
+	}
+
+	log.Info("picking up jobs from usb drive", zap.String("device", dev.DevName), zap.String("label", dev.Label))
+	b.mounted[dev.DevName] = mountPoint
+}
+
+// detach unmounts a removed USB drive
+func (b *spoolBackend) detach(dev usb.StorageDevice) {
+	mountPoint, ok := b.mounted[dev.DevName]
+	if !ok {
+		return
+	}
+	delete(b.mounted, dev.DevName)
+
+	if err := usb.Unmount(mountPoint); err != nil {
+		log.Error("could not unmount usb drive", zap.String("device", dev.DevName), zap.Error(err))
+	}
+}
+
+// detachAll unmounts all USB drives, the results of running jobs go to the local spool directory
+func (b *spoolBackend) detachAll() {
+	for devName := range b.mounted {
+		b.detach(usb.StorageDevice{DevName: devName})
+	}
+}
+
+// NewSpoolBackend returns a backend that reads the jobs from the spool directories and executes them with the given backend,
+// USB drives are only watched if a device manager is passed
+func NewSpoolBackend(executor Backend, settings config.SpoolSettings, devices *usb.USBDeviceManager) Spool {
+	b := &spoolBackend{
+		Backend:  executor,
+		local:    settings.Directory(),
+		mediaDir: config.DefaultMediaDir,
+		mounted:  make(map[string]string),
+		interval: SpoolScanInterval,
+	}
+
+	if settings.Removable {
+		b.usb = devices
+	}
+
+	return b
+}
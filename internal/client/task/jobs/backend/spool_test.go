@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/internal/client/task/jobs/spool"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpoolBackend(t *testing.T) {
+	log.Init(true)
+
+	dir := t.TempDir()
+	b := NewSpoolBackend(nil, config.SpoolSettings{Enabled: true, Dir: dir}, nil)
+	b.(*spoolBackend).interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scheduled := make(chan api.FixedJob, 4)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Run(ctx, func(job api.FixedJob, jobDir string, sink api.JobSink) error {
+			assert.Equal(t, dir, jobDir)
+			assert.NotNil(t, sink)
+			if job.Command != "get_status" {
+				return errors.New("no handler")
+			}
+
+			scheduled <- job
+			return nil
+		})
+	}()
+
+	// Run creates the jobs folder, files dropped in later are picked up with the next scan
+	jobs := filepath.Join(dir, spool.JobsFolder)
+	assert.Eventually(t, func() bool { _, err := os.Stat(jobs); return err == nil }, time.Second, 5*time.Millisecond)
+	assert.NoError(t, os.WriteFile(filepath.Join(jobs, "42.json"), []byte(`{"command":"get_status"}`), 0640))
+	assert.NoError(t, os.WriteFile(filepath.Join(jobs, "43.json"), []byte(`{"command":"unknown"}`), 0640))
+
+	select {
+	case job := <-scheduled:
+		assert.Equal(t, "42", job.Id)
+	case <-time.After(time.Second):
+		t.Fatal("spooled job was not scheduled")
+	}
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(jobs, spool.RejectedFolder, "43.json.error"))
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+	assert.FileExists(t, filepath.Join(jobs, spool.AcceptedFolder, "42.json"))
+
+	cancel()
+	<-done
+
+	// Every file is only handled once
+	assert.Empty(t, scheduled)
+}
@@ -35,8 +35,12 @@ decimation=4
 	//`
 	// StartupCheckTimeout The time after which the startup check should be considered timed out
 	StartupCheckTimeout = 10 * time.Second
+
+	// CaptureDataRate Rough upper bound of the bytes per second a capture writes, the pre-flight check keeps room for it
+	CaptureDataRate = 50 * 1000
 )
 
+// A missing SDR is usually found by the pre-flight checks already, the startup check still catches a busy one
 var (
 	StartupCheckStrings = []StartupResult{
 		// Return if we found using hackrf one
@@ -0,0 +1,37 @@
+package iridium
+
+import (
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+)
+
+// Registration returns the iridium sniffing job type, it needs the SDR exclusively, half of the CPU and room for the capture
+func Registration() registry.Registration {
+	return registry.Registration{
+		Command: "iridium_sniffing",
+		Arguments: []registry.Argument{
+			{Name: "centerfrequency_mhz", Description: "center frequency of the capture", Type: registry.Float, Unit: "MHz", Range: &registry.Range{Min: 1, Max: 6000}, Default: "1621.5"},
+			{Name: "bandwidth_mhz", Description: "bandwidth of the capture", Type: registry.Float, Unit: "MHz", Range: &registry.Range{Min: 2, Max: 20}, Default: "5"},
+			{Name: "bandwidth_khz", Description: "bandwidth of the capture, alternative to bandwidth_mhz", Type: registry.Float, Unit: "kHz", Range: &registry.Range{Min: 2000, Max: 20000}},
+			{Name: "gain", Description: "RF amplifier gain", Type: registry.Int, Unit: "dB", Range: &registry.Range{Min: 0, Max: 14}, Default: "14"},
+			{Name: "if_gain", Description: "IF (LNA) gain", Type: registry.Int, Unit: "dB", Range: &registry.Range{Min: 0, Max: 40}, Default: "40"},
+			{Name: "bb_gain", Description: "baseband (VGA) gain", Type: registry.Int, Unit: "dB", Range: &registry.Range{Min: 0, Max: 62}, Default: "20"},
+		},
+		Check:     checkBandwidth,
+		Resources: scheduler.ResourceClaims{scheduler.SDRDevice1, scheduler.Exclusive(scheduler.CPUShare, 50), scheduler.Exclusive(scheduler.DiskWriteBandwidth, CaptureDataRate/1024)},
+		Preflight: []preflight.Check{preflight.SDRAttached(), preflight.FreeSpaceFor(CaptureDataRate), preflight.Temperature()},
+		Handler:   IridiumSniffing,
+	}
+}
+
+// checkBandwidth rejects jobs that set the bandwidth twice
+func checkBandwidth(args map[string]string) []registry.Violation {
+	_, mhz := args["bandwidth_mhz"]
+	_, khz := args["bandwidth_khz"]
+	if mhz && khz {
+		return []registry.Violation{{Argument: "bandwidth_khz", Problem: "conflicts with bandwidth_mhz"}}
+	}
+
+	return nil
+}
@@ -10,16 +10,20 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
-	"time"
 
 	"github.com/LeoCommon/client/internal/client/api"
 	"github.com/LeoCommon/client/internal/client/constants"
 	"github.com/LeoCommon/client/internal/client/task/jobs"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
 	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/pkg/clock"
 	"github.com/LeoCommon/client/pkg/file"
 	"github.com/LeoCommon/client/pkg/misc"
+	"github.com/LeoCommon/client/pkg/progress"
 	"github.com/LeoCommon/client/pkg/system/cli"
 	"github.com/LeoCommon/client/pkg/system/streamhelpers"
 
@@ -62,11 +66,11 @@ func (j *SniffingJob) ParseJobArguments() {
 }
 
 func (j *SniffingJob) getJobStoragePath() string {
-	return filepath.Join(j.app.Conf.JobStoragePath(), j.job.Name)
+	return filepath.Join(j.app.Conf.JobStoragePath(), j.job.FileID())
 }
 
 func (j *SniffingJob) getJobFileName(suffix string) string {
-	return j.job.Name + suffix
+	return j.job.FileID() + suffix
 }
 
 func (j *SniffingJob) addOutputFile(path string) {
@@ -96,7 +100,7 @@ func (j *SniffingJob) writeJobInfoFile() error {
 func (j *SniffingJob) getStatusFilePath(statusType StatusType) string {
 	return filepath.Join(
 		j.getJobStoragePath(),
-		fmt.Sprintf("%s_%s.txt", j.job.Name, string(statusType)),
+		fmt.Sprintf("%s_%s.txt", j.job.FileID(), string(statusType)),
 	)
 }
 
@@ -175,7 +179,7 @@ func (j *SniffingJob) writeServiceLogFile() error {
 }
 
 func (j *SniffingJob) getArchiveName() string {
-	return fmt.Sprintf("job_%s_sensor_%s.zip", j.job.Name, j.app.Conf.SensorName())
+	return fmt.Sprintf("job_%s_sensor_%s.zip", j.job.FileID(), j.app.Conf.SensorName())
 }
 
 func (j *SniffingJob) zipAndUpload(ctx context.Context) error {
@@ -194,8 +198,12 @@ func (j *SniffingJob) zipAndUpload(ctx context.Context) error {
 		_ = os.Remove(name)
 	}(archivePath)
 
+	if err := result.AddArtifact(ctx, archivePath); err != nil {
+		log.Warn("could not record the job archive", zap.Error(err))
+	}
+
 	// upload zip to server
-	err = j.app.Api.PostSensorData(ctx, j.job.Id, archivePath)
+	err = j.uplink.PostSensorData(ctx, j.job.Id, archivePath)
 	if err != nil {
 		log.Error("Error uploading job-archive to server", zap.Error(err))
 	}
@@ -216,7 +224,7 @@ func (j *SniffingJob) cleanup() error {
 	return err
 }
 
-func monitorIridiumSniffingStartup(scanner *bufio.Scanner) error {
+func monitorIridiumSniffingStartup(clk clock.Clock, scanner *bufio.Scanner) error {
 	result := make(chan error)
 	go func() {
 		for scanner.Scan() {
@@ -237,16 +245,64 @@ func monitorIridiumSniffingStartup(scanner *bufio.Scanner) error {
 		result <- streamhelpers.NewTerminatedEarlyError(nil)
 	}()
 
+	timer := clk.NewTimer(StartupCheckTimeout)
+	defer timer.Stop()
+
 	select {
 	// Forward the result of our check function
 	case err := <-result:
 		return err
 	// Same for the timeout
-	case <-time.After(StartupCheckTimeout):
+	case <-timer.C():
 		return misc.NewTimedOutError("startup check timed", StartupCheckTimeout)
 	}
 }
 
+// parseFrameCount returns the number of frames decoded so far from a gr-iridium statistics line
+// e.g. "1683724981 | i: 210/s | i_avg: 198/s | q_max: 4 | i_ok: 48% | o: 101/s | ok: 48% | ok: 101/s | ok_avg: 47% | ok: 12034 | ok_avg: 95/s | d: 0"
+func parseFrameCount(line string) (int, bool) {
+	for _, field := range strings.Split(line, "|") {
+		value, found := strings.CutPrefix(strings.TrimSpace(field), "ok: ")
+		if !found {
+			continue
+		}
+
+		// The other ok fields are rates or percentages
+		if frames, err := strconv.Atoi(value); err == nil {
+			return frames, true
+		}
+	}
+
+	return 0, false
+}
+
+// monitorIridiumSniffingProgress reports the capture progress from the statistics gr-iridium prints to stderr
+func (j *SniffingJob) monitorIridiumSniffingProgress(ctx context.Context, scanner *bufio.Scanner, capturePath string) {
+	// The job might have started late, so the capture window ends at its EndTime
+	begin := j.clock.Monotonic()
+	window := j.job.EndTime.Sub(j.clock.Now())
+
+	for scanner.Scan() {
+		frames, ok := parseFrameCount(scanner.Text())
+		if !ok {
+			continue
+		}
+		result.SetMetric(ctx, "frames", float64(frames))
+
+		percent := -1.0
+		if window > 0 {
+			percent = float64(j.clock.Monotonic()-begin) / float64(window) * 100.0
+		}
+
+		message := fmt.Sprintf("%d frames", frames)
+		if info, err := os.Stat(capturePath); err == nil {
+			message += fmt.Sprintf(" %.1f MB written", float64(info.Size())/1e6)
+		}
+
+		progress.Report(ctx, progress.Update{Stage: "capture", Percent: percent, Message: message})
+	}
+}
+
 func IridiumSniffing(ctx context.Context, job api.FixedJob, jp *schema.JobParameters) error {
 	if jp.Config.Iridium.Disabled {
 		return jobs.ErrJobDisabled
@@ -254,8 +310,10 @@ func IridiumSniffing(ctx context.Context, job api.FixedJob, jp *schema.JobParame
 
 	// Create sniffing data type
 	j := SniffingJob{
-		job: job,
-		app: jp.App,
+		job:    job,
+		app:    jp.App,
+		uplink: jp.Uplink(),
+		clock:  jp.TimeSource(),
 	}
 
 	// Parse the job arguments and populate the required fields
@@ -326,14 +384,15 @@ func IridiumSniffing(ctx context.Context, job api.FixedJob, jp *schema.JobParame
 	cmdReader.Start()
 
 	// Block and check for common error symptoms in the stream
-	err = monitorIridiumSniffingStartup(bufio.NewScanner(stdErrReader))
-
-	// Detach and close from the pipe
-	cmdReader.DetachStream(stdErrPipeWriter)
-	stdErrReader.Close()
+	stdErrScanner := bufio.NewScanner(stdErrReader)
+	err = monitorIridiumSniffingStartup(j.clock, stdErrScanner)
 
 	// If there was some sort of error, abort now
 	if err != nil {
+		// Detach and close from the pipe
+		cmdReader.DetachStream(stdErrPipeWriter)
+		stdErrReader.Close()
+
 		log.Warn("startup error encountered, cancelling and forwarding error", zap.Error(err))
 		// cancel the cmd context, so the process terminates (if it did not already)
 		cancel()
@@ -351,8 +410,29 @@ func IridiumSniffing(ctx context.Context, job api.FixedJob, jp *schema.JobParame
 	log.Info("startup successfull, sniffing now", zap.Error(err))
 	defer cancel()
 
+	captureStarted := j.clock.Monotonic()
+
+	// Keep reading the stderr pipe for the capture statistics
+	var progressWG sync.WaitGroup
+	progressWG.Add(1)
+	go func() {
+		defer progressWG.Done()
+		j.monitorIridiumSniffingProgress(ctx, stdErrScanner, captureOutputPath)
+	}()
+
 	// Wait for the result
 	errFin := <-cmdReader.Wait()
+
+	// Detach and close from the pipe, this ends the progress monitor
+	cmdReader.DetachStream(stdErrPipeWriter)
+	stdErrReader.Close()
+	progressWG.Wait()
+
+	result.SetMetric(ctx, "capture_seconds", (j.clock.Monotonic() - captureStarted).Seconds())
+	if info, err := os.Stat(captureOutputPath); err == nil {
+		result.SetMetric(ctx, "capture_bytes", float64(info.Size()))
+	}
+
 	if errFin != nil {
 		log.Error("sniffing job did not terminate correctly", zap.Error(errFin))
 		//return err
@@ -377,7 +457,8 @@ func IridiumSniffing(ctx context.Context, job api.FixedJob, jp *schema.JobParame
 
 	// zip all files (job-file + start-/end-status + sniffing files) and upload them
 	// todo prepare some handler to cancel uploads
-	errUp := j.zipAndUpload(context.TODO())
+	// the upload is not cancelled with the job, but keeps the progress reporter of the context
+	errUp := j.zipAndUpload(context.WithoutCancel(ctx))
 
 	if errFin != nil {
 		return errFin
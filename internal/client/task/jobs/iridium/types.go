@@ -3,6 +3,7 @@ package iridium
 import (
 	"github.com/LeoCommon/client/internal/client"
 	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/pkg/clock"
 )
 
 type SniffingConfig struct {
@@ -17,6 +18,10 @@ type SniffingJob struct {
 	app            *client.App
 	configFilePath string
 	job            api.FixedJob
+	// Receives the capture archive, see schema.JobParameters.Uplink
+	uplink api.JobSink
+	// Measures the capture, see schema.JobParameters.TimeSource
+	clock clock.Clock
 	// output file list
 	outputFiles []string
 	config      SniffingConfig
@@ -19,7 +19,9 @@ import (
 
 	"github.com/LeoCommon/client/internal/client"
 	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
 	"github.com/LeoCommon/client/internal/client/constants"
+	"github.com/LeoCommon/client/internal/client/task/jobs/result"
 	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
 	"github.com/LeoCommon/client/pkg/file"
 	"github.com/LeoCommon/client/pkg/log"
@@ -63,8 +65,26 @@ func GetDefaultSensorStatus(app *client.App) (api.SensorStatus, error) {
 	return status, nil
 }
 
-func PushStatus(jp *schema.JobParameters) error {
+func PushStatus(ctx context.Context, job api.FixedJob, jp *schema.JobParameters) error {
 	newStatus, _ := GetDefaultSensorStatus(jp.App)
+
+	// Jobs from the spool directory have no server to check in with, the status is kept as file
+	if jp.Sink != nil {
+		statusJSON, err := json.MarshalIndent(newStatus, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		filePath := filepath.Join(jp.App.Conf.JobTempPath(), "job_"+job.FileID()+"_status.json")
+		if err := file.WriteTo(filePath, string(statusJSON)); err != nil {
+			return err
+		}
+		defer os.Remove(filePath)
+
+		recordArtifact(ctx, filePath)
+		return jp.Sink.PostSensorData(ctx, job.Id, filePath)
+	}
+
 	return jp.App.Api.PutSensorUpdate(newStatus)
 }
 
@@ -102,7 +122,6 @@ func GetFullNetworkStatus(jp *schema.JobParameters) string {
 
 func ReportFullStatus(ctx context.Context, job api.FixedJob, jp *schema.JobParameters) error {
 	sensorName := jp.App.Conf.SensorName()
-	jobName := job.Name
 	jobId := job.Id
 	newStatus, _ := GetDefaultSensorStatus(jp.App)
 	statusString, err := json.Marshal(newStatus)
@@ -114,16 +133,19 @@ func ReportFullStatus(ctx context.Context, job api.FixedJob, jp *schema.JobParam
 	diskStatus, _ := cli.GetDiskStatus()
 	timingStatus, _ := cli.GetTimingStatus()
 	systemctlStatus, _ := cli.GetSystemdStatus()
+	schedulerStatus := GetSchedulerStatus(jp.App)
 	totalStatus := sensorName + "\n\n" + string(statusString) + "\n\nRauc-Status:\n" + raucStatus + "\nNetwork-Status:\n" + networkStatus +
-		"\nDisk-Status:\n" + diskStatus + "\nTiming-Status:\n" + timingStatus + "\nSystemctl-Status:\n" + systemctlStatus
-	filename := "job_" + jobName + "_sensor_" + sensorName + ".txt"
+		"\nDisk-Status:\n" + diskStatus + "\nTiming-Status:\n" + timingStatus + "\nSystemctl-Status:\n" + systemctlStatus +
+		"\nScheduler-Status:\n" + schedulerStatus
+	filename := "job_" + job.FileID() + "_sensor_" + sensorName + ".txt"
 	filePath := filepath.Join(jp.App.Conf.JobTempPath(), filename)
 	err = file.WriteTo(filePath, totalStatus)
 	if err != nil {
 		log.Error("Error writing file: " + err.Error())
 		return err
 	}
-	err = jp.App.Api.PostSensorData(ctx, jobId, filePath)
+	recordArtifact(ctx, filePath)
+	err = jp.Uplink().PostSensorData(ctx, jobId, filePath)
 	if err != nil {
 		log.Error("Uploading did not work!" + err.Error())
 		return err
@@ -136,16 +158,36 @@ func ReportFullStatus(ctx context.Context, job api.FixedJob, jp *schema.JobParam
 	return nil
 }
 
+// GetSchedulerStatus returns the queued, running and recently ended tasks as json
+func GetSchedulerStatus(app *client.App) string {
+	if app.Scheduler == nil {
+		return "scheduler not running\n"
+	}
+
+	status, err := json.MarshalIndent(app.Scheduler.Snapshot(), "", "  ")
+	if err != nil {
+		return "could not encode scheduler status: " + err.Error() + "\n"
+	}
+
+	return string(status) + "\n"
+}
+
+// recordArtifact adds the uploaded file to the job result
+func recordArtifact(ctx context.Context, path string) {
+	if err := result.AddArtifact(ctx, path); err != nil {
+		log.Warn("could not record the job artifact", zap.String("file", path), zap.Error(err))
+	}
+}
+
 func GetLogs(ctx context.Context, job api.FixedJob, jp *schema.JobParameters) error {
 	serviceName := job.Arguments["service"]
 	if len(serviceName) == 0 {
 		serviceName = constants.ClientServiceName
 	}
 
-	jobName := job.Name
 	sensorName := jp.App.Conf.SensorName()
 
-	filename := "job_" + jobName + "_sensor_" + sensorName + ".txt"
+	filename := "job_" + job.FileID() + "_sensor_" + sensorName + ".txt"
 	filePath := filepath.Join(jp.Config.TempDir.String(), filename)
 
 	serviceLogs, err := cli.GetServiceLogs(serviceName)
@@ -158,7 +200,8 @@ func GetLogs(ctx context.Context, job api.FixedJob, jp *schema.JobParameters) er
 		log.Error("Error writing file: " + err.Error())
 		return err
 	}
-	err = jp.App.Api.PostSensorData(ctx, job.Id, filePath)
+	recordArtifact(ctx, filePath)
+	err = jp.Uplink().PostSensorData(ctx, job.Id, filePath)
 	if err != nil {
 		log.Error("Uploading did not work!" + err.Error())
 		return err
@@ -177,10 +220,9 @@ func GetConfig(ctx context.Context, job api.FixedJob, jp *schema.JobParameters)
 		configType = "all"
 	}
 
-	jobName := job.Name
 	sensorName := jp.App.Conf.SensorName()
 
-	filename := "job_" + jobName + "_sensor_" + sensorName + ".txt"
+	filename := "job_" + job.FileID() + "_sensor_" + sensorName + ".txt"
 	filePath := filepath.Join(jp.Config.TempDir.String(), filename)
 
 	configData := "type:" + configType + "\n"
@@ -201,7 +243,8 @@ func GetConfig(ctx context.Context, job api.FixedJob, jp *schema.JobParameters)
 		log.Error("Error writing file: " + err.Error())
 		return err
 	}
-	err = jp.App.Api.PostSensorData(ctx, job.Id, filePath)
+	recordArtifact(ctx, filePath)
+	err = jp.Uplink().PostSensorData(ctx, job.Id, filePath)
 	if err != nil {
 		log.Error("Uploading did not work!" + err.Error())
 		return err
@@ -215,17 +258,21 @@ func GetConfig(ctx context.Context, job api.FixedJob, jp *schema.JobParameters)
 }
 
 func SetConfig(job api.FixedJob, jp *schema.JobParameters) error {
-	configsMap := job.Arguments
+	return ApplyConfig(jp.App.Conf, job.Arguments)
+}
+
+// ApplyConfig changes the client settings, e.g. "polling_interval", unknown keys are ignored
+func ApplyConfig(conf *config.Manager, configsMap map[string]string) error {
 	err := error(nil)
 	for key := range configsMap {
 		if key == "job_temp_path" {
-			err = jp.App.Conf.SetJobTempPath(configsMap[key])
+			err = conf.SetJobTempPath(configsMap[key])
 		} else if key == "job_storage_path" {
-			err = jp.App.Conf.SetJobStoragePath(configsMap[key])
+			err = conf.SetJobStoragePath(configsMap[key])
 		} else if key == "polling_interval" {
-			err = jp.App.Conf.SetPollingInterval(configsMap[key])
+			err = conf.SetPollingInterval(configsMap[key])
 		} else if key == "upload_chunksize_byte" {
-			err = jp.App.Conf.SetUploadChunkSize(configsMap[key])
+			err = conf.SetUploadChunkSize(configsMap[key])
 		}
 		if err != nil {
 			log.Error("Error setting config " + key + "=" + configsMap[key] + ": " + err.Error())
@@ -254,10 +301,8 @@ func RebootSensor(job api.FixedJob, jp *schema.JobParameters) error {
 	return fmt.Errorf("reboot not implemented at the moment")
 
 	/*
-			jobName := job.name
-
 			// Assume everything works and send a "finished" status (later you can't send it).
-			err := api.PutJobUpdate(jobName, "finished")
+			err := api.PutJobUpdate(job, "finished")
 			if err != nil {
 				log.Error("Error when contacting server before reboot-job execution", zap.Error(err))
 				return err
@@ -266,7 +311,7 @@ func RebootSensor(job api.FixedJob, jp *schema.JobParameters) error {
 			err = cli.PrepareSoftReboot()
 			if err != nil {
 				log.Error("Error when performing reboot-job", zap.Error(err))
-				err := api.PutJobUpdate(jobName, "failed")
+				err := api.PutJobUpdate(job, "failed")
 				if err != nil {
 					log.Error("Error during sending error in reboot-job", zap.Error(err))
 					return err
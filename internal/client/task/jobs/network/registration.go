@@ -0,0 +1,84 @@
+package network
+
+import (
+	"context"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/pkg/misc"
+	"github.com/LeoCommon/client/pkg/system/services/net"
+)
+
+// Activating a connection should never take longer
+const JobTimeout = 5 * time.Minute
+
+var onOff = []string{misc.StateON, misc.StateOFF}
+
+// ipArguments are the settings shared by all connection types
+var ipArguments = []registry.Argument{
+	{Name: autoconnect, Description: "activate the connection automatically", Type: registry.Bool, Default: "true"},
+	{Name: methodv4, Description: "how the address is configured", Enum: []string{v4auto, v4manual, v4disabled}, Default: v4auto},
+	{Name: addressesv4, Description: "address with prefix length, required for manual", Type: registry.Prefix},
+	{Name: gatewayv4, Description: "gateway, used for manual", Type: registry.IP},
+	{Name: dnsv4, Description: "custom dns server", Type: registry.IP},
+}
+
+// checkManual requires the address for manual configurations
+func checkManual(args map[string]string) []registry.Violation {
+	if _, ok := args[addressesv4]; args[methodv4] == v4manual && !ok {
+		return []registry.Violation{{Argument: addressesv4, Problem: "required for manual"}}
+	}
+
+	return nil
+}
+
+// setConfig returns the handler that configures the given connection type
+func setConfig(netType net.NetworkInterfaceType) registry.Handler {
+	return func(_ context.Context, job api.FixedJob, jp *schema.JobParameters) error {
+		return SetConfig(job, jp, netType)
+	}
+}
+
+// Registrations returns the network job types
+func Registrations() []registry.Registration {
+	return []registry.Registration{
+		{
+			Command: "set_network_conn",
+			Arguments: []registry.Argument{
+				{Name: coneth, Description: "ethernet on or off", Enum: onOff},
+				{Name: conwifi, Description: "wifi on or off", Enum: onOff},
+				{Name: congsm, Description: "gsm on or off", Enum: onOff},
+			},
+			Timeout: JobTimeout,
+			Handler: func(_ context.Context, job api.FixedJob, jp *schema.JobParameters) error {
+				return SetNetworkConnectivity(job, jp)
+			},
+		},
+		{
+			Command: "set_wifi_config",
+			Arguments: append([]registry.Argument{
+				{Name: wifissid, Description: "name of the wifi", Required: true},
+				{Name: wifipsk, Description: "wifi password", Required: true},
+			}, ipArguments...),
+			Timeout: JobTimeout,
+			Check:   checkManual,
+			Handler: setConfig(net.WiFi),
+		},
+		{
+			Command:   "set_eth_config",
+			Arguments: ipArguments,
+			Timeout:   JobTimeout,
+			Check:     checkManual,
+			Handler:   setConfig(net.Ethernet),
+		},
+		{
+			Command:   "set_gsm_config",
+			Arguments: ipArguments,
+			Timeout:   JobTimeout,
+			Check:     checkManual,
+			Handler:   setConfig(net.GSM),
+		},
+	}
+}
@@ -0,0 +1,223 @@
+package preflight
+
+// Pre-flight checks tell whether a job can run on the sensor before anything is started
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client"
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/pkg/file"
+	"github.com/LeoCommon/client/pkg/system/cli"
+	"github.com/LeoCommon/client/pkg/system/services/net"
+	"github.com/LeoCommon/client/pkg/usb"
+)
+
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+var (
+	// Wraps the reason of a job that failed its checks when it was about to start
+	ErrFailed = errors.New("pre-flight check failed")
+
+	// Returned by checks that do not apply, e.g. on sensors without the service they look at
+	ErrSkipped = errors.New("check does not apply")
+
+	ErrNoSDR          = errors.New("no SDR attached")
+	ErrNoUSBDevice    = errors.New("usb device not attached")
+	ErrLowDiskSpace   = errors.New("not enough free space")
+	ErrNoGNSSFix      = errors.New("no valid GNSS fix")
+	ErrTooHot         = errors.New("temperature above the limit")
+	ErrNoConnectivity = errors.New("no suitable network connection")
+)
+
+// ConnectivityClass is the kind of network connection a job needs
+type ConnectivityClass string
+
+const (
+	// Any connection that reaches the internet
+	ConnectivityOnline ConnectivityClass = "online"
+	// Ethernet or WiFi, for jobs that should not move their data over GSM
+	ConnectivityUnmetered ConnectivityClass = "unmetered"
+)
+
+// Overridden by the tests
+var readTemperature = cli.GetTemperature
+
+// Env is what the checks look at
+type Env struct {
+	App    *client.App
+	Job    api.FixedJob
+	Config config.JobsConfig
+	// The length of the job window, the data a job writes grows with it
+	Duration time.Duration
+	// Set for jobs that report to a spool directory, they need no uplink
+	Local bool
+}
+
+// Check is a single pre-flight check, Run returns nil if it passed
+type Check struct {
+	Name string
+	Run  func(env Env) error
+}
+
+// Run runs all checks, ok is false if any of them failed. The caller sets the result code of the failed ones
+func Run(checks []Check, env Env) ([]api.CheckResult, bool) {
+	results := make([]api.CheckResult, 0, len(checks))
+	ok := true
+
+	for _, check := range checks {
+		err := check.Run(env)
+		r := api.CheckResult{Name: check.Name, Status: StatusPassed}
+		switch {
+		case errors.Is(err, ErrSkipped):
+			r.Status = StatusSkipped
+			r.Message = err.Error()
+		case err != nil:
+			r.Status = StatusFailed
+			r.Message = err.Error()
+			ok = false
+		}
+
+		results = append(results, r)
+	}
+
+	return results, ok
+}
+
+// FirstFailed returns the first failed check
+func FirstFailed(results []api.CheckResult) (api.CheckResult, bool) {
+	for _, r := range results {
+		if r.Status == StatusFailed {
+			return r, true
+		}
+	}
+
+	return api.CheckResult{}, false
+}
+
+// Failure returns the first failed check as error, nil if none failed
+func Failure(results []api.CheckResult) error {
+	r, failed := FirstFailed(results)
+	if !failed {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s: %s", ErrFailed, r.Name, r.Message)
+}
+
+// SDRAttached checks that an SDR is attached
+func SDRAttached() Check {
+	return usbDevice("sdr", ErrNoSDR, usb.SDRTypes)
+}
+
+// USBDevice checks that a device of any of the types is attached
+func USBDevice(types ...usb.DeviceType) Check {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		if device, ok := usb.SupportedDevices[t]; ok {
+			names = append(names, device.Name)
+		}
+	}
+
+	return usbDevice("usb_device", fmt.Errorf("%w: %s", ErrNoUSBDevice, strings.Join(names, " or ")), types)
+}
+
+func usbDevice(name string, missing error, types []usb.DeviceType) Check {
+	return Check{Name: name, Run: func(env Env) error {
+		if env.App.UsbManager == nil {
+			return fmt.Errorf("%w: usb devices are not managed", ErrSkipped)
+		}
+		if !env.App.UsbManager.Attached(types...) {
+			return missing
+		}
+
+		return nil
+	}}
+}
+
+// FreeSpace checks that the storage path has the configured free space left
+func FreeSpace() Check {
+	return FreeSpaceFor(0)
+}
+
+// FreeSpaceFor checks that the storage path has room for the data the job writes at the rate in bytes per second
+// during its window, on top of the configured free space
+func FreeSpaceFor(bytesPerSecond uint64) Check {
+	return Check{Name: "disk_space", Run: func(env Env) error {
+		free, err := file.FreeSpace(env.Config.StorageDir.String())
+		if err != nil {
+			return err
+		}
+
+		want := env.Config.Preflight.MinFreeSpace() + bytesPerSecond*uint64(env.Duration/time.Second)
+		if free < want {
+			return fmt.Errorf("%w: %d MB left in %s, %d MB needed", ErrLowDiskSpace, free/1000/1000, env.Config.StorageDir.String(), want/1000/1000)
+		}
+
+		return nil
+	}}
+}
+
+// GNSSFix checks that the GNSS receiver has a valid fix
+func GNSSFix() Check {
+	return Check{Name: "gnss_fix", Run: func(env Env) error {
+		if env.App.GNSSService == nil {
+			return fmt.Errorf("%w: no GNSS receiver", ErrSkipped)
+		}
+		if !env.App.GNSSService.GetData().Valid() {
+			return ErrNoGNSSFix
+		}
+
+		return nil
+	}}
+}
+
+// Temperature checks that the sensor is below the configured temperature
+func Temperature() Check {
+	return Check{Name: "temperature", Run: func(env Env) error {
+		celsius, err := readTemperature()
+		if err != nil {
+			return fmt.Errorf("%w: temperature not available: %v", ErrSkipped, err)
+		}
+
+		if limit := env.Config.Preflight.TemperatureLimit(); celsius > limit {
+			return fmt.Errorf("%w: %.1f°C, limit %.1f°C", ErrTooHot, celsius, limit)
+		}
+
+		return nil
+	}}
+}
+
+// Connectivity checks that the sensor has a network connection of the class, jobs that report to a spool directory pass
+func Connectivity(class ConnectivityClass) Check {
+	return Check{Name: "connectivity", Run: func(env Env) error {
+		network := env.App.NetworkService
+		if env.Local {
+			return fmt.Errorf("%w: the job reports to a spool directory", ErrSkipped)
+		}
+		if network == nil {
+			return fmt.Errorf("%w: no network service", ErrSkipped)
+		}
+
+		if !network.HasConnectivity() {
+			return fmt.Errorf("%w: offline", ErrNoConnectivity)
+		}
+		if class == ConnectivityUnmetered {
+			ethernet, _ := network.IsNetworkTypeActive(net.Ethernet)
+			wifi, _ := network.IsNetworkTypeActive(net.WiFi)
+			if !ethernet && !wifi {
+				return fmt.Errorf("%w: %s needs ethernet or wifi", ErrNoConnectivity, class)
+			}
+		}
+
+		return nil
+	}}
+}
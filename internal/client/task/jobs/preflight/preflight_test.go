@@ -0,0 +1,72 @@
+package preflight
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client"
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/LeoCommon/client/pkg/usb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	log.Init(true)
+
+	checks := []Check{
+		{Name: "ok", Run: func(env Env) error { return nil }},
+		{Name: "missing", Run: func(env Env) error { return ErrSkipped }},
+		{Name: "broken", Run: func(env Env) error { return errors.New("broken") }},
+	}
+
+	results, ok := Run(checks, Env{App: &client.App{}})
+	assert.False(t, ok)
+	assert.Len(t, results, 3)
+	assert.Equal(t, StatusPassed, results[0].Status)
+	assert.Equal(t, StatusSkipped, results[1].Status)
+	assert.Equal(t, StatusFailed, results[2].Status)
+	assert.Empty(t, results[2].Code)
+	assert.Equal(t, "broken", results[2].Message)
+
+	err := Failure(results)
+	assert.ErrorIs(t, err, ErrFailed)
+	assert.ErrorContains(t, err, "broken: broken")
+
+	results, ok = Run(checks[:2], Env{App: &client.App{}})
+	assert.True(t, ok)
+	assert.NoError(t, Failure(results))
+}
+
+func TestChecks(t *testing.T) {
+	log.Init(true)
+
+	env := Env{App: &client.App{}, Config: config.JobsConfig{StorageDir: config.StoragePath(t.TempDir())}}
+
+	// Sensors without the services can not tell
+	assert.ErrorIs(t, SDRAttached().Run(env), ErrSkipped)
+	assert.ErrorIs(t, USBDevice(usb.ModemSIM7600).Run(env), ErrSkipped)
+	assert.ErrorIs(t, GNSSFix().Run(env), ErrSkipped)
+	assert.ErrorIs(t, Connectivity(ConnectivityOnline).Run(env), ErrSkipped)
+	env.Local = true
+	assert.ErrorIs(t, Connectivity(ConnectivityUnmetered).Run(env), ErrSkipped)
+
+	// The data of the job window has to fit as well
+	assert.NoError(t, FreeSpace().Run(env))
+	env.Duration = time.Hour
+	assert.NoError(t, FreeSpaceFor(1000).Run(env))
+	assert.ErrorIs(t, FreeSpaceFor(1<<40).Run(env), ErrLowDiskSpace)
+	env.Config.Preflight.MinFreeSpaceMB = 1 << 40
+	assert.ErrorIs(t, FreeSpace().Run(env), ErrLowDiskSpace)
+
+	defer func(read func() (float64, error)) { readTemperature = read }(readTemperature)
+	readTemperature = func() (float64, error) { return 70, nil }
+	assert.NoError(t, Temperature().Run(env))
+	readTemperature = func() (float64, error) { return 85, nil }
+	assert.ErrorIs(t, Temperature().Run(env), ErrTooHot)
+	env.Config.Preflight.MaxTemperature = 90
+	assert.NoError(t, Temperature().Run(env))
+	readTemperature = func() (float64, error) { return 0, errors.New("no sensor") }
+	assert.ErrorIs(t, Temperature().Run(env), ErrSkipped)
+}
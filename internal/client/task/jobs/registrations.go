@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/constants"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+const (
+	// Jobs that only report or change some state
+	StatusJobTimeout = 5 * time.Minute
+	// Jobs that upload a report
+	UploadJobTimeout = 30 * time.Minute
+)
+
+// Jobs that upload a report need a connection when they start, spooled jobs write it to the outbox instead
+var uploadChecks = []preflight.Check{preflight.Connectivity(preflight.ConnectivityOnline)}
+
+// Collecting and packing a report takes some CPU and disk bandwidth, concurrent uploads share it so only one counts
+var uploadResources = scheduler.ResourceClaims{scheduler.Shared(scheduler.CPUShare, 25), scheduler.Shared(scheduler.DiskWriteBandwidth, 1024)}
+
+// Changes the client settings, pushed configuration changes are checked against its arguments
+const SetConfigCommand = "set_sys_config"
+
+// Registrations returns the generic sensor job types
+func Registrations() []registry.Registration {
+	return []registry.Registration{
+		{
+			Command: "get_status",
+			Timeout: StatusJobTimeout,
+			Handler: PushStatus,
+		},
+		{
+			Command:   "get_full_status",
+			Timeout:   UploadJobTimeout,
+			Resources: uploadResources,
+			Preflight: uploadChecks,
+			Handler:   ReportFullStatus,
+		},
+		{
+			Command: "get_logs",
+			Arguments: []registry.Argument{
+				{Name: "service", Description: "systemd service whose logs are uploaded", Default: constants.ClientServiceName},
+			},
+			Timeout:   UploadJobTimeout,
+			Resources: uploadResources,
+			Preflight: uploadChecks,
+			Handler:   GetLogs,
+		},
+		{
+			Command: "get_sys_config",
+			Arguments: []registry.Argument{
+				{Name: "type", Description: "all uploads the configuration, shortcut returns it as error", Enum: []string{"all", "shortcut"}, Default: "all"},
+			},
+			Timeout:   UploadJobTimeout,
+			Resources: uploadResources,
+			Preflight: uploadChecks,
+			Handler:   GetConfig,
+		},
+		{
+			Command: SetConfigCommand,
+			Arguments: []registry.Argument{
+				{Name: "job_temp_path", Description: "directory for temporary job files"},
+				{Name: "job_storage_path", Description: "directory for job output"},
+				{Name: "polling_interval", Description: "interval between job polls", Type: registry.Duration, Unit: "s", Range: &registry.Range{Min: 1, Max: 24 * 60 * 60}},
+				{Name: "upload_chunksize_byte", Description: "size of the uploaded chunks", Type: registry.Int, Unit: "B", Range: &registry.Range{Min: 1000, Max: math.MaxInt32}},
+			},
+			Timeout: StatusJobTimeout,
+			Handler: func(_ context.Context, job api.FixedJob, jp *schema.JobParameters) error {
+				return SetConfig(job, jp)
+			},
+		},
+		{
+			Command: "reboot",
+			Timeout: StatusJobTimeout,
+			Handler: func(_ context.Context, job api.FixedJob, jp *schema.JobParameters) error {
+				return RebootSensor(job, jp)
+			},
+		},
+		{
+			Command: "reset",
+			Handler: func(_ context.Context, job api.FixedJob, jp *schema.JobParameters) error {
+				return ResetSensor(job, jp)
+			},
+		},
+	}
+}
+
+// ResetSensor reports the job as finished, assuming everything works as there is no chance to do so afterwards, and resets the sensor
+func ResetSensor(job api.FixedJob, jp *schema.JobParameters) error {
+	err := jp.Uplink().PutJobUpdate(job, job.StepStatus("finished"))
+	if err != nil {
+		log.Info("hasty push reset result 'finished'", zap.String("name", job.Name), zap.NamedError("PutJobUpdate", err))
+	}
+
+	return ForceReset()
+}
@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+)
+
+var (
+	ErrInvalidRegistration = errors.New("job registration needs a command name and a handler")
+	ErrCommandRegistered   = errors.New("a job with this command name is already registered")
+)
+
+// Handler executes a job of a registered type
+type Handler func(ctx context.Context, job api.FixedJob, jp *schema.JobParameters) error
+
+// Argument describes an argument a job type accepts
+type Argument struct {
+	Name        string
+	Description string
+	// The value type, defaults to String
+	Type Type
+	// Unit of numeric values, only informational
+	Unit     string
+	Required bool
+	// Allowed bounds of numeric values
+	Range *Range
+	// Allowed values, empty means any value of the type
+	Enum []string
+	// Used if the argument is not set, empty if there is none
+	Default string
+}
+
+// Registration defines a job type
+type Registration struct {
+	// The command name the server sends, matched exactly (case insensitive)
+	Command   string
+	Arguments []Argument
+	// Resources the job claims for its entire window
+	Resources scheduler.ResourceClaims
+	// Optional upper bound for the run time, the job window always applies.
+	// Short jobs like status reports should not block a worker until their window ends
+	Timeout time.Duration
+	// Optional rules that span several arguments, run after the arguments are checked on their own
+	Check func(args map[string]string) []Violation
+	// Optional checks of the sensor state, they run ahead of the start time so problems are reported early
+	Preflight []preflight.Check
+	Handler   Handler
+}
+
+// Argument returns the description of the argument with the given name
+func (r Registration) Argument(name string) (Argument, bool) {
+	idx := slices.IndexFunc(r.Arguments, func(a Argument) bool { return a.Name == name })
+	if idx < 0 {
+		return Argument{}, false
+	}
+
+	return r.Arguments[idx], true
+}
+
+// Registry maps the command names to the job types
+type Registry struct {
+	m    sync.RWMutex
+	jobs map[string]Registration
+}
+
+func New() *Registry {
+	return &Registry{jobs: make(map[string]Registration)}
+}
+
+// normalize makes the command lookup case insensitive
+func normalize(command string) string {
+	return strings.ToLower(strings.TrimSpace(command))
+}
+
+// Register adds a job type, every command name can only be registered once
+func (r *Registry) Register(reg Registration) error {
+	command := normalize(reg.Command)
+	if len(command) == 0 || reg.Handler == nil {
+		return ErrInvalidRegistration
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.jobs[command]; ok {
+		return ErrCommandRegistered
+	}
+
+	reg.Command = command
+	r.jobs[command] = reg
+	return nil
+}
+
+// Lookup returns the job type registered for the exact command name
+func (r *Registry) Lookup(command string) (Registration, bool) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	reg, ok := r.jobs[normalize(command)]
+	return reg, ok
+}
+
+// Commands returns the sorted names of all registered job types
+func (r *Registry) Commands() []string {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	commands := make([]string, 0, len(r.jobs))
+	for command := range r.jobs {
+		commands = append(commands, command)
+	}
+	slices.Sort(commands)
+
+	return commands
+}
@@ -0,0 +1,44 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/schema"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func noop(_ context.Context, _ api.FixedJob, _ *schema.JobParameters) error { return nil }
+
+func TestRegistry(t *testing.T) {
+	r := New()
+	assert.NoError(t, r.Register(Registration{Command: "get_status", Handler: noop}))
+	assert.NoError(t, r.Register(Registration{Command: "get_full_status", Handler: noop}))
+	assert.NoError(t, r.Register(Registration{
+		Command:   "iridium_sniffing",
+		Arguments: []Argument{{Name: "gain", Default: "14"}},
+		Resources: scheduler.ResourceClaims{scheduler.SDRDevice1},
+		Handler:   noop,
+	}))
+
+	assert.ErrorIs(t, r.Register(Registration{Command: "GET_STATUS", Handler: noop}), ErrCommandRegistered)
+	assert.ErrorIs(t, r.Register(Registration{Command: " ", Handler: noop}), ErrInvalidRegistration)
+	assert.ErrorIs(t, r.Register(Registration{Command: "reset"}), ErrInvalidRegistration)
+
+	// Commands are matched exactly, substrings of registered commands are unknown
+	reg, ok := r.Lookup(" Iridium_Sniffing")
+	assert.True(t, ok)
+	assert.Equal(t, scheduler.ResourceClaims{scheduler.SDRDevice1}, reg.Resources)
+	arg, ok := reg.Argument("gain")
+	assert.True(t, ok)
+	assert.Equal(t, "14", arg.Default)
+
+	_, ok = r.Lookup("status")
+	assert.False(t, ok)
+	_, ok = r.Lookup("iridium")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"get_full_status", "get_status", "iridium_sniffing"}, r.Commands())
+}
@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the type of an argument value, all values arrive as strings
+type Type string
+
+const (
+	String   Type = "string"
+	Bool     Type = "bool"
+	Int      Type = "int"
+	Float    Type = "float"
+	Duration Type = "duration"
+	// An IP address like 1.2.3.4
+	IP Type = "ip"
+	// An IP address with prefix length like 1.2.3.4/24
+	Prefix Type = "prefix"
+)
+
+// Range limits a numeric argument, both bounds are inclusive
+type Range struct {
+	Min float64
+	Max float64
+}
+
+// Violation is a single problem with the arguments of a job
+type Violation struct {
+	Argument string
+	Problem  string
+}
+
+func (v Violation) String() string {
+	return v.Argument + ":" + v.Problem
+}
+
+// ValidationError holds all violations found in the arguments of a job
+type ValidationError struct {
+	Command    string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid arguments for %s: %s", e.Command, e.Reason())
+}
+
+// Reason lists the violations in the argument syntax of the server, e.g. gain:above_maximum_14;ssid:required
+func (e *ValidationError) Reason() string {
+	violations := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		violations = append(violations, strings.ReplaceAll(v.String(), " ", "_"))
+	}
+
+	return strings.Join(violations, ";")
+}
+
+// number parses the value of a numeric argument
+func (a Argument) number(value string) (float64, string) {
+	switch a.Type {
+	case Int:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, "not an integer"
+		}
+		return float64(n), ""
+	case Float:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, "not a number"
+		}
+		return n, ""
+	case Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, "not a duration"
+		}
+		// Ranges of durations are given in seconds
+		return d.Seconds(), ""
+	}
+
+	return 0, ""
+}
+
+// check returns the problem with the value, empty if there is none
+func (a Argument) check(value string) string {
+	switch a.Type {
+	case Bool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "not a bool"
+		}
+	case IP:
+		if _, err := netip.ParseAddr(value); err != nil {
+			return "not an ip address"
+		}
+	case Prefix:
+		if _, err := netip.ParsePrefix(value); err != nil {
+			return "not an ip address with prefix length"
+		}
+	case Int, Float, Duration:
+		n, problem := a.number(value)
+		if len(problem) != 0 {
+			return problem
+		}
+
+		if a.Range != nil && n < a.Range.Min {
+			return "below minimum " + strconv.FormatFloat(a.Range.Min, 'f', -1, 64) + a.Unit
+		}
+		if a.Range != nil && n > a.Range.Max {
+			return "above maximum " + strconv.FormatFloat(a.Range.Max, 'f', -1, 64) + a.Unit
+		}
+	}
+
+	if len(a.Enum) != 0 && !slices.Contains(a.Enum, value) {
+		return "not one of " + strings.Join(a.Enum, ",")
+	}
+
+	return ""
+}
+
+// Validate checks the arguments against the declared ones and returns a *ValidationError with all violations
+func (r Registration) Validate(args map[string]string) error {
+	violations := []Violation{}
+
+	for _, arg := range r.Arguments {
+		value, ok := args[arg.Name]
+		if !ok {
+			if arg.Required {
+				violations = append(violations, Violation{arg.Name, "required"})
+			}
+			continue
+		}
+
+		if problem := arg.check(value); len(problem) != 0 {
+			violations = append(violations, Violation{arg.Name, problem})
+		}
+	}
+
+	for name := range args {
+		if _, ok := r.Argument(name); !ok {
+			violations = append(violations, Violation{name, "unknown argument"})
+		}
+	}
+
+	// Cross-argument rules only make sense if every argument is fine on its own
+	if len(violations) == 0 && r.Check != nil {
+		violations = r.Check(args)
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	// Maps have no order, keep the reason stable for the server
+	slices.SortFunc(violations, func(a, b Violation) int { return strings.Compare(a.Argument, b.Argument) })
+	return &ValidationError{Command: r.Command, Violations: violations}
+}
@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	reg := Registration{
+		Command: "iridium_sniffing",
+		Arguments: []Argument{
+			{Name: "centerfrequency_mhz", Type: Float, Unit: "MHz", Range: &Range{Min: 1, Max: 6000}},
+			{Name: "gain", Type: Int, Unit: "dB", Range: &Range{Min: 0, Max: 14}},
+			{Name: "interval", Type: Duration, Range: &Range{Min: 1, Max: 60}},
+			{Name: "method", Enum: []string{"auto", "manual"}},
+			{Name: "autoconnect", Type: Bool},
+			{Name: "address", Type: Prefix},
+			{Name: "dns", Type: IP},
+			{Name: "ssid", Required: true},
+		},
+		Check: func(args map[string]string) []Violation {
+			if _, ok := args["address"]; args["method"] == "manual" && !ok {
+				return []Violation{{"address", "required for manual"}}
+			}
+			return nil
+		},
+		Handler: noop,
+	}
+
+	assert.NoError(t, reg.Validate(map[string]string{
+		"centerfrequency_mhz": "1621.5",
+		"gain":                "14",
+		"interval":            "30s",
+		"method":              "manual",
+		"autoconnect":         "true",
+		"address":             "10.0.0.2/24",
+		"dns":                 "8.8.8.8",
+		"ssid":                "sensor",
+	}))
+
+	// Every violation is reported at once, sorted by argument
+	err := reg.Validate(map[string]string{
+		"centerfrequency_mhz": "high",
+		"gain":                "47",
+		"interval":            "2m",
+		"method":              "dhcp",
+		"autoconnect":         "maybe",
+		"address":             "10.0.0.2",
+		"dns":                 "dns.google",
+		"bandwidth":           "5",
+	})
+	var invalid *ValidationError
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []Violation{
+		{"address", "not an ip address with prefix length"},
+		{"autoconnect", "not a bool"},
+		{"bandwidth", "unknown argument"},
+		{"centerfrequency_mhz", "not a number"},
+		{"dns", "not an ip address"},
+		{"gain", "above maximum 14dB"},
+		{"interval", "above maximum 60"},
+		{"method", "not one of auto,manual"},
+		{"ssid", "required"},
+	}, invalid.Violations)
+	assert.True(t, strings.HasPrefix(invalid.Reason(), "address:not_an_ip_address_with_prefix_length;autoconnect:not_a_bool;"))
+
+	// Cross-argument rules run once the arguments are fine on their own
+	err = reg.Validate(map[string]string{"method": "manual", "ssid": "sensor"})
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "address:required_for_manual", invalid.Reason())
+}
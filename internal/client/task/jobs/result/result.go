@@ -0,0 +1,189 @@
+package result
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/misc"
+	"github.com/LeoCommon/client/pkg/system/streamhelpers"
+	"github.com/LeoCommon/client/pkg/usb"
+)
+
+// Code classifies why a job did not finish, the server aggregates them so they must never change
+type Code string
+
+const (
+	CodeNone             Code = ""
+	CodeFailed           Code = "failed"
+	CodeInvalidArguments Code = "invalid_arguments"
+	CodeUnsupported      Code = "unsupported_command"
+	CodeSchedulingError  Code = "scheduling_error"
+	CodeExpired          Code = "expired"
+	CodePreempted        Code = "preempted"
+	CodeDependencyFailed Code = "dependency_failed"
+	CodeInterrupted      Code = "interrupted"
+	CodeDraining         Code = "draining"
+	CodeCanceled         Code = "canceled"
+	CodeTimeout          Code = "timeout"
+	CodeCrashed          Code = "crashed"
+	CodeDeviceStuck      Code = "device_stuck"
+	CodeProcessFailed    Code = "process_failed"
+	CodeInvalidSignature Code = "invalid_signature"
+	CodePreflightFailed  Code = "preflight_failed"
+)
+
+// Classify returns the code of a job error, CodeFailed if there is no better one
+func Classify(err error) Code {
+	var panicErr *scheduler.PanicError
+	var invalid *registry.ValidationError
+
+	switch {
+	case err == nil:
+		return CodeNone
+	case errors.As(err, &invalid):
+		return CodeInvalidArguments
+	case errors.As(err, &panicErr):
+		return CodeCrashed
+	case errors.Is(err, scheduler.ErrTaskPreempted):
+		return CodePreempted
+	case errors.Is(err, scheduler.ErrSchedulerDraining):
+		return CodeDraining
+	case errors.Is(err, scheduler.ErrTaskExpired):
+		return CodeExpired
+	case errors.Is(err, scheduler.ErrDependencyFailed):
+		return CodeDependencyFailed
+	case errors.Is(err, preflight.ErrFailed):
+		return CodePreflightFailed
+	case errors.Is(err, scheduler.ErrTaskAborted):
+		return CodeInterrupted
+	case errors.Is(err, scheduler.ErrResourceSharingNotPossible):
+		return CodeSchedulingError
+	case errors.Is(err, &usb.StuckError{}), errors.Is(err, &streamhelpers.ProcessStuckError{}):
+		return CodeDeviceStuck
+	case errors.Is(err, &streamhelpers.TerminatedEarlyError{}):
+		return CodeProcessFailed
+	case errors.Is(err, &misc.TimedOutError{}), errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeCanceled
+	}
+
+	return CodeFailed
+}
+
+// New returns the result of a job that did not run, the code and message are derived from err
+func New(job api.FixedJob, status string, err error, finished time.Time) api.JobResult {
+	r := api.JobResult{
+		JobID:      job.Id,
+		Step:       job.Step,
+		Status:     status,
+		Code:       string(Classify(err)),
+		FinishedAt: finished.UnixMilli(),
+	}
+
+	if err != nil {
+		r.Message = err.Error()
+	}
+
+	return r
+}
+
+// Recorder collects the artifacts and metrics of a running job
+type Recorder struct {
+	m         sync.Mutex
+	artifacts []api.Artifact
+	metrics   map[string]float64
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{metrics: make(map[string]float64)}
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context that passes the recorder to the job
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// AddArtifact records the size and hash of a file the job produced, it does nothing without a recorder
+func AddArtifact(ctx context.Context, path string) error {
+	r, ok := ctx.Value(recorderKey{}).(*Recorder)
+	if !ok {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.artifacts = append(r.artifacts, api.Artifact{
+		Name:   filepath.Base(path),
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	})
+
+	return nil
+}
+
+// SetMetric records a job specific value, e.g. the number of decoded frames
+func SetMetric(ctx context.Context, name string, value float64) {
+	r, ok := ctx.Value(recorderKey{}).(*Recorder)
+	if !ok {
+		return
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.metrics[name] = value
+}
+
+// Result returns the result of a job run with the recorded artifacts and metrics,
+// the timings and attempts are taken from the task context of the scheduler
+func (r *Recorder) Result(ctx context.Context, job api.FixedJob, status string, err error, finished time.Time) api.JobResult {
+	res := New(job, status, err, finished)
+
+	scheduled, started := scheduler.Timing(ctx)
+	if !scheduled.IsZero() {
+		res.ScheduledAt = scheduled.UnixMilli()
+	}
+	if !started.IsZero() {
+		res.StartedAt = started.UnixMilli()
+	}
+	res.Attempt, res.MaxAttempts = scheduler.Attempt(ctx)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	if len(r.artifacts) != 0 {
+		res.Artifacts = append([]api.Artifact{}, r.artifacts...)
+	}
+	if len(r.metrics) != 0 {
+		res.Metrics = make(map[string]float64, len(r.metrics))
+		for name, value := range r.metrics {
+			res.Metrics[name] = value
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,64 @@
+package result
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/task/jobs/preflight"
+	"github.com/LeoCommon/client/internal/client/task/jobs/registry"
+	"github.com/LeoCommon/client/internal/client/task/scheduler"
+	"github.com/LeoCommon/client/pkg/misc"
+	"github.com/LeoCommon/client/pkg/usb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	assert.Equal(t, CodeNone, Classify(nil))
+	assert.Equal(t, CodeFailed, Classify(errors.New("upload rejected")))
+	assert.Equal(t, CodeInvalidArguments, Classify(&registry.ValidationError{Command: "reboot"}))
+	assert.Equal(t, CodeCrashed, Classify(&scheduler.PanicError{Value: "nil map"}))
+	assert.Equal(t, CodePreempted, Classify(fmt.Errorf("capture: %w", scheduler.ErrTaskPreempted)))
+	assert.Equal(t, CodeDeviceStuck, Classify(errors.Join(errors.New("capture"), &usb.StuckError{})))
+	assert.Equal(t, CodeTimeout, Classify(&misc.TimedOutError{}))
+	assert.Equal(t, CodeTimeout, Classify(context.DeadlineExceeded))
+	assert.Equal(t, CodeCanceled, Classify(context.Canceled))
+	assert.Equal(t, CodePreflightFailed, Classify(fmt.Errorf("%w: sdr: no SDR attached", preflight.ErrFailed)))
+}
+
+func TestRecorder(t *testing.T) {
+	artifact := filepath.Join(t.TempDir(), "capture.zip")
+	assert.NoError(t, os.WriteFile(artifact, []byte("frames"), 0644))
+
+	// Without a recorder the job runs as before
+	assert.NoError(t, AddArtifact(context.Background(), artifact))
+	SetMetric(context.Background(), "frames", 1)
+
+	rec := NewRecorder()
+	ctx := WithRecorder(context.Background(), rec)
+	assert.NoError(t, AddArtifact(ctx, artifact))
+	assert.Error(t, AddArtifact(ctx, artifact+".missing"))
+	SetMetric(ctx, "frames", 1)
+	SetMetric(ctx, "frames", 12034)
+
+	finished := time.Unix(1700000000, 0)
+	job := api.FixedJob{Id: "42", Name: "capture"}
+	res := rec.Result(ctx, job, "failed", fmt.Errorf("capture: %w", &usb.StuckError{}), finished)
+	assert.Equal(t, "42", res.JobID)
+	assert.Equal(t, "failed", res.Status)
+	assert.Equal(t, string(CodeDeviceStuck), res.Code)
+	assert.NotEmpty(t, res.Message)
+	assert.Equal(t, finished.UnixMilli(), res.FinishedAt)
+	assert.Zero(t, res.StartedAt)
+	assert.Equal(t, []api.Artifact{{
+		Name:   "capture.zip",
+		Size:   6,
+		SHA256: "594cfd2607d4f09e1ce6241203e418cacfed545063792bc5eab7afaadbf0c4e0",
+	}}, res.Artifacts)
+	assert.Equal(t, map[string]float64{"frames": 12034}, res.Metrics)
+}
@@ -1,8 +1,13 @@
 package schema
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/LeoCommon/client/internal/client"
+	"github.com/LeoCommon/client/internal/client/api"
 	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/pkg/clock"
 )
 
 type JobParameters struct {
@@ -11,4 +16,59 @@ type JobParameters struct {
 
 	// A copy of the jobConfig
 	Config config.JobsConfig
+
+	// Set for jobs from a spool directory, the directory they were read from
+	Spool string
+	// Receives the updates and files of the job, the server if unset
+	Sink api.JobSink
+	// The clock of the task handler, the system clock if unset
+	Clock clock.Clock
+}
+
+// Uplink returns where the job reports its updates and uploads its files
+func (jp *JobParameters) Uplink() api.JobSink {
+	if jp.Sink != nil {
+		return jp.Sink
+	}
+
+	return jp.App.Api
+}
+
+// TimeSource returns the clock the job measures its time with
+func (jp *JobParameters) TimeSource() clock.Clock {
+	if jp.Clock != nil {
+		return jp.Clock
+	}
+
+	return clock.Real
+}
+
+// persistedJobParameters is the serialized form, the App and Sink are runtime state and have to be re-attached
+type persistedJobParameters struct {
+	Job    api.FixedJob      `json:"job"`
+	Config config.JobsConfig `json:"config"`
+	Spool  string            `json:"spool,omitempty"`
+}
+
+// MarshalJSON serializes the job and config so the parameters can be journaled
+func (jp *JobParameters) MarshalJSON() ([]byte, error) {
+	job, ok := jp.Job.(api.FixedJob)
+	if !ok {
+		return nil, fmt.Errorf("unsupported job type %T", jp.Job)
+	}
+
+	return json.Marshal(persistedJobParameters{Job: job, Config: jp.Config, Spool: jp.Spool})
+}
+
+// UnmarshalJSON restores the job and config, the App field is left untouched
+func (jp *JobParameters) UnmarshalJSON(data []byte) error {
+	p := persistedJobParameters{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+
+	jp.Job = p.Job
+	jp.Config = p.Config
+	jp.Spool = p.Spool
+	return nil
 }
@@ -0,0 +1,146 @@
+package signing
+
+// The server signs a document that binds the job to its sensors and an expiry. Only the signed
+// job is executed, the unsigned fields next to it are ignored except for the server side states.
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
+)
+
+var (
+	ErrUnsigned         = errors.New("job is not signed")
+	ErrBadSignature     = errors.New("job signature is invalid")
+	ErrSignatureExpired = errors.New("job signature expired")
+	ErrWrongSensor      = errors.New("job is signed for other sensors")
+	ErrJobMismatch      = errors.New("signed job does not match the job")
+	ErrNotCanonical     = errors.New("job command is not in its canonical form")
+)
+
+// Document is the signed form of a job
+type Document struct {
+	Job     api.FixedJob `json:"job"`
+	Sensors []string     `json:"sensors"`
+	// Unix timestamp, the signature is not accepted afterwards
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// Verifier checks the job signatures against the pinned keys
+type Verifier struct {
+	settings config.JobSigningSettings
+	keys     []ed25519.PublicKey
+	sensor   string
+}
+
+// NewVerifier returns a verifier for the jobs of the sensor, nil if no key is pinned
+func NewVerifier(settings config.JobSigningSettings, sensor string) (*Verifier, error) {
+	if !settings.Enabled() {
+		return nil, nil
+	}
+
+	keys, err := settings.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{settings: settings, keys: keys, sensor: sensor}, nil
+}
+
+// canonical returns true if the command and the step commands are lower case without surrounding spaces,
+// the registry would match the others to a command the policy does not see
+func canonical(job api.FixedJob) bool {
+	isCanonical := func(command string) bool { return command == strings.ToLower(strings.TrimSpace(command)) }
+
+	return isCanonical(job.Command) && !slices.ContainsFunc(job.Steps, func(step api.JobStep) bool { return !isCanonical(step.Command) })
+}
+
+// required returns true if the job or any of its steps runs a command that has to be signed
+func (v *Verifier) required(job api.FixedJob) bool {
+	if v.settings.Required(job.Command) {
+		return true
+	}
+
+	return slices.ContainsFunc(job.Steps, func(step api.JobStep) bool { return v.settings.Required(step.Command) })
+}
+
+// Verify returns the signed job, unsigned jobs are returned as they are unless the policy requires a signature
+func (v *Verifier) Verify(job api.FixedJob, now time.Time) (api.FixedJob, error) {
+	// Nothing is pinned, the jobs are trusted as far as the TLS channel
+	if v == nil {
+		return job, nil
+	}
+
+	if !canonical(job) {
+		return job, ErrNotCanonical
+	}
+
+	if job.Signature == nil {
+		if v.required(job) {
+			return job, ErrUnsigned
+		}
+
+		return job, nil
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(job.Signature.Payload)
+	if err != nil {
+		return job, ErrBadSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(job.Signature.Signature)
+	if err != nil {
+		return job, ErrBadSignature
+	}
+
+	valid := slices.ContainsFunc(v.keys, func(key ed25519.PublicKey) bool { return ed25519.Verify(key, payload, sig) })
+	if !valid {
+		return job, ErrBadSignature
+	}
+
+	var doc Document
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return job, ErrBadSignature
+	}
+
+	if doc.ExpiresAt == 0 || now.After(time.Unix(doc.ExpiresAt, 0)) {
+		return job, ErrSignatureExpired
+	}
+	if !slices.Contains(doc.Sensors, v.sensor) {
+		return job, ErrWrongSensor
+	}
+	if doc.Job.Id != job.Id {
+		return job, ErrJobMismatch
+	}
+	if !canonical(doc.Job) {
+		return job, ErrNotCanonical
+	}
+
+	// The states change while the job runs, they are never signed
+	signed := doc.Job
+	signed.Status = job.Status
+	signed.States = job.States
+	signed.Signature = job.Signature
+
+	return signed, nil
+}
+
+// Sign creates the signature of a job for the sensors, the reference for the server side
+func Sign(job api.FixedJob, sensors []string, expires time.Time, key ed25519.PrivateKey) (*api.JobSignature, error) {
+	job.Signature = nil
+	payload, err := json.Marshal(Document{Job: job, Sensors: sensors, ExpiresAt: expires.Unix()})
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.JobSignature{
+		Payload:   base64.StdEncoding.EncodeToString(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	}, nil
+}
@@ -0,0 +1,126 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newKey(t *testing.T) (string, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(public), private
+}
+
+func TestVerify(t *testing.T) {
+	public, private := newKey(t)
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	v, err := NewVerifier(config.JobSigningSettings{PublicKeys: []string{public}}, "sensor")
+	assert.NoError(t, err)
+
+	job := api.FixedJob{Id: "42", Command: "reset", Arguments: map[string]string{}, StartTime: now, EndTime: now.Add(time.Hour)}
+	job.Signature, err = Sign(job, []string{"sensor"}, now.Add(time.Hour), private)
+	assert.NoError(t, err)
+
+	// The server states are taken from the unsigned job, everything else from the signed one
+	sent := job
+	sent.Status = "pending"
+	sent.Arguments = map[string]string{"injected": "true"}
+	verified, err := v.Verify(sent, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "pending", verified.Status)
+	assert.Empty(t, verified.Arguments)
+	assert.Equal(t, job.StartTime, verified.StartTime)
+
+	// Expired signatures
+	_, err = v.Verify(job, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrSignatureExpired)
+
+	// Signed for another sensor
+	other := job
+	other.Signature, _ = Sign(job, []string{"other"}, now.Add(time.Hour), private)
+	_, err = v.Verify(other, now)
+	assert.ErrorIs(t, err, ErrWrongSensor)
+
+	// The signature of another job
+	moved := job
+	moved.Id = "43"
+	_, err = v.Verify(moved, now)
+	assert.ErrorIs(t, err, ErrJobMismatch)
+
+	// Signed with a key that is not pinned
+	_, foreign := newKey(t)
+	forged := job
+	forged.Signature, _ = Sign(job, []string{"sensor"}, now.Add(time.Hour), foreign)
+	_, err = v.Verify(forged, now)
+	assert.ErrorIs(t, err, ErrBadSignature)
+
+	// A signed command that is not canonical
+	odd := job
+	odd.Command = "Reset "
+	sent = job
+	sent.Signature, _ = Sign(odd, []string{"sensor"}, now.Add(time.Hour), private)
+	_, err = v.Verify(sent, now)
+	assert.ErrorIs(t, err, ErrNotCanonical)
+
+	// A tampered payload
+	tampered := job
+	tampered.Signature = &api.JobSignature{Payload: base64.StdEncoding.EncodeToString([]byte(`{"job":{"id":"42"}}`)), Signature: job.Signature.Signature}
+	_, err = v.Verify(tampered, now)
+	assert.ErrorIs(t, err, ErrBadSignature)
+}
+
+func TestVerifyPolicy(t *testing.T) {
+	public, _ := newKey(t)
+	now := time.Now()
+
+	// Without a pinned key everything passes
+	v, err := NewVerifier(config.JobSigningSettings{}, "sensor")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	_, err = v.Verify(api.FixedJob{Id: "42", Command: "reset"}, now)
+	assert.NoError(t, err)
+
+	// The default policy only covers the commands that change the system
+	v, err = NewVerifier(config.JobSigningSettings{PublicKeys: []string{public}}, "sensor")
+	assert.NoError(t, err)
+	_, err = v.Verify(api.FixedJob{Id: "42", Command: "get_status"}, now)
+	assert.NoError(t, err)
+
+	// Padded and mixed case commands reach the same handlers, they must not slip past the policy
+	for _, command := range []string{"RESET", "reset ", "\tset_wifi_config", " Reboot\n", "Get_Status"} {
+		_, err = v.Verify(api.FixedJob{Id: "42", Command: command}, now)
+		assert.ErrorIs(t, err, ErrNotCanonical, command)
+	}
+	padded := api.FixedJob{Id: "42", Command: "composite", Steps: []api.JobStep{{Name: "a", Command: "get_status"}, {Name: "b", Command: "reset "}}}
+	_, err = v.Verify(padded, now)
+	assert.ErrorIs(t, err, ErrNotCanonical)
+
+	// A single step is enough
+	composite := api.FixedJob{Id: "42", Command: "composite", Steps: []api.JobStep{{Name: "a", Command: "get_status"}, {Name: "b", Command: "set_wifi_config"}}}
+	_, err = v.Verify(composite, now)
+	assert.ErrorIs(t, err, ErrUnsigned)
+
+	// The policy matches the commands like the registry does
+	settings := config.JobSigningSettings{PublicKeys: []string{public}}
+	assert.True(t, settings.Required(" reset\t"))
+	assert.True(t, settings.Required("Set_WiFi_Config"))
+	assert.False(t, settings.Required("get_status"))
+
+	// Everything has to be signed
+	v, err = NewVerifier(config.JobSigningSettings{PublicKeys: []string{public}, Require: []string{config.AllCommands}}, "sensor")
+	assert.NoError(t, err)
+	_, err = v.Verify(api.FixedJob{Id: "42", Command: "get_status"}, now)
+	assert.ErrorIs(t, err, ErrUnsigned)
+
+	// Keys that are not Ed25519 keys
+	_, err = NewVerifier(config.JobSigningSettings{PublicKeys: []string{base64.StdEncoding.EncodeToString([]byte("short"))}}, "sensor")
+	assert.Error(t, err)
+}
@@ -0,0 +1,238 @@
+package spool
+
+// A spool directory replaces the server for sensors without uplink:
+//
+//	jobs/           job files in the api.FixedJob format, e.g. 42.json
+//	jobs/accepted/  job files that were scheduled
+//	jobs/rejected/  job files that can not run, with the reason in <file>.error
+//	outbox/<id>/    status.log, result.json and the files of the job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/pkg/file"
+	"github.com/LeoCommon/client/pkg/log"
+)
+
+const (
+	JobsFolder     = "jobs"
+	AcceptedFolder = "accepted"
+	RejectedFolder = "rejected"
+	OutboxFolder   = "outbox"
+
+	// USB drives carry their spool directory in this folder
+	MediaFolder = "leocommon"
+
+	StatusFileName = "status.log"
+	ResultFileName = "result.json"
+
+	jobFileExtension = ".json"
+	errorExtension   = ".error"
+)
+
+var ErrNoSpool = errors.New("spool directory not available")
+
+// JobFile is a job file of a spool directory
+type JobFile struct {
+	Path string
+	Job  api.FixedJob
+	// Set if the file is not a valid job
+	Err error
+}
+
+// Scan reads the job files of the spool directory in the order of their names
+func Scan(dir string) ([]JobFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, JobsFolder, "*"+jobFileExtension))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	files := make([]JobFile, 0, len(paths))
+	for _, path := range paths {
+		f := JobFile{Path: path}
+
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &f.Job)
+		}
+		if err == nil && len(f.Job.Id) == 0 {
+			// Use the file name, e.g. 42.json
+			f.Job.Id = strings.TrimSuffix(filepath.Base(path), jobFileExtension)
+		}
+		f.Err = err
+
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// Settle moves a handled job file out of the way, rejected files get the reason next to them
+func Settle(f JobFile, rejection error) error {
+	folder := AcceptedFolder
+	if rejection != nil {
+		folder = RejectedFolder
+	}
+
+	target := filepath.Join(filepath.Dir(f.Path), folder, filepath.Base(f.Path))
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return err
+	}
+
+	if rejection != nil {
+		if err := file.WriteTo(target+errorExtension, rejection.Error()+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(f.Path, target)
+}
+
+// Outbox keeps the updates, results and files of the jobs of a spool directory, it implements api.JobSink
+type Outbox struct {
+	m sync.Mutex
+	// The spool directory the jobs were read from
+	dir string
+	// Used once dir is gone, e.g. the USB drive was removed
+	fallback string
+}
+
+func NewOutbox(dir string, fallback string) *Outbox {
+	return &Outbox{dir: dir, fallback: fallback}
+}
+
+// jobDir returns the outbox directory of the job, it is created if needed
+func (o *Outbox) jobDir(jobID string) (string, error) {
+	root := o.dir
+	if _, err := os.Stat(filepath.Join(root, JobsFolder)); err != nil {
+		if len(o.fallback) == 0 {
+			return "", ErrNoSpool
+		}
+
+		log.Warn("spool directory is gone, writing to the fallback", zap.String("dir", o.dir), zap.String("fallback", o.fallback))
+		root = o.fallback
+	}
+
+	// The id might contain anything, never leave the outbox
+	id := (&api.FixedJob{Id: jobID}).FileID()
+	dir := filepath.Join(root, OutboxFolder, id)
+
+	return dir, os.MkdirAll(dir, 0750)
+}
+
+// PutJobUpdate appends the status to the status log of the job
+func (o *Outbox) PutJobUpdate(job api.FixedJob, status string) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	return o.appendStatus(job, status)
+}
+
+// appendStatus must be called with the lock held
+func (o *Outbox) appendStatus(job api.FixedJob, status string) error {
+	dir, err := o.jobDir(job.Id)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, StatusFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(f, "%s %s\n", time.Now().UTC().Format(time.RFC3339), status)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// PutJobResult logs the final status and writes the result document, steps of composite jobs get their own
+func (o *Outbox) PutJobResult(job api.FixedJob, status string, result api.JobResult) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	if err := o.appendStatus(job, status); err != nil {
+		return err
+	}
+
+	dir, err := o.jobDir(job.Id)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := ResultFileName
+	if len(job.Step) != 0 {
+		name = strings.TrimSuffix(name, ".json") + "_" + (&api.FixedJob{Id: job.Step}).FileID() + ".json"
+	}
+
+	return file.WriteTo(filepath.Join(dir, name), string(data))
+}
+
+// PostSensorData copies the file into the outbox of the job
+func (o *Outbox) PostSensorData(ctx context.Context, jobID string, filePath string) error {
+	dir, err := o.jobDir(jobID)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	target := filepath.Join(dir, filepath.Base(filePath))
+	dst, err := os.Create(target + ".part")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, &contextReader{ctx: ctx, r: src})
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(target + ".part")
+		return err
+	}
+
+	return os.Rename(target+".part", target)
+}
+
+// contextReader stops copying large files once the job is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
@@ -0,0 +1,99 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LeoCommon/client/internal/client/api"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanAndSettle(t *testing.T) {
+	log.Init(true)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, JobsFolder), 0750))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, JobsFolder, "b.json"), []byte(`{"command":"get_status"}`), 0640))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, JobsFolder, "a.json"), []byte(`{"id":"42","command":"reboot"}`), 0640))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, JobsFolder, "c.json"), []byte(`not a job`), 0640))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, JobsFolder, "notes.txt"), []byte(`ignored`), 0640))
+
+	files, err := Scan(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	// Sorted by name, the id defaults to the file name
+	assert.Equal(t, "42", files[0].Job.Id)
+	assert.Equal(t, "b", files[1].Job.Id)
+	assert.Equal(t, "get_status", files[1].Job.Command)
+	assert.Error(t, files[2].Err)
+
+	assert.NoError(t, Settle(files[0], nil))
+	assert.NoError(t, Settle(files[2], errors.New("invalid job file")))
+
+	assert.FileExists(t, filepath.Join(dir, JobsFolder, AcceptedFolder, "a.json"))
+	assert.FileExists(t, filepath.Join(dir, JobsFolder, RejectedFolder, "c.json"))
+	reason, err := os.ReadFile(filepath.Join(dir, JobsFolder, RejectedFolder, "c.json.error"))
+	assert.NoError(t, err)
+	assert.Equal(t, "invalid job file\n", string(reason))
+
+	// Only the unsettled file is left
+	files, err = Scan(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "b", files[0].Job.Id)
+}
+
+func TestOutbox(t *testing.T) {
+	log.Init(true)
+
+	dir := t.TempDir()
+	fallback := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, JobsFolder), 0750))
+	assert.NoError(t, os.MkdirAll(filepath.Join(fallback, JobsFolder), 0750))
+
+	var sink api.JobSink = NewOutbox(dir, fallback)
+	job := api.FixedJob{Id: "42", Command: "get_status"}
+
+	assert.NoError(t, sink.PutJobUpdate(job, "running"))
+	assert.NoError(t, sink.PutJobResult(job, "finished", api.JobResult{Status: "finished"}))
+
+	jobDir := filepath.Join(dir, OutboxFolder, "42")
+	status, err := os.ReadFile(filepath.Join(jobDir, StatusFileName))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(status)), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasSuffix(lines[0], " running"))
+	assert.True(t, strings.HasSuffix(lines[1], " finished"))
+	assert.FileExists(t, filepath.Join(jobDir, ResultFileName))
+
+	// Steps of composite jobs keep their own result
+	step := job
+	step.Step = "capture"
+	assert.NoError(t, sink.PutJobResult(step, "capture:finished", api.JobResult{Status: "finished"}))
+	assert.FileExists(t, filepath.Join(jobDir, "result_capture.json"))
+
+	data := filepath.Join(t.TempDir(), "capture.zip")
+	assert.NoError(t, os.WriteFile(data, []byte("data"), 0640))
+	assert.NoError(t, sink.PostSensorData(context.Background(), job.Id, data))
+	assert.FileExists(t, filepath.Join(jobDir, "capture.zip"))
+	assert.NoFileExists(t, filepath.Join(jobDir, "capture.zip.part"))
+
+	// Cancelled jobs leave no partial copies behind
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, sink.PostSensorData(ctx, "43", data), context.Canceled)
+	assert.NoFileExists(t, filepath.Join(dir, OutboxFolder, "43", "capture.zip.part"))
+
+	// The drive was removed, the results go to the fallback
+	assert.NoError(t, os.RemoveAll(dir))
+	assert.NoError(t, sink.PutJobUpdate(job, "finished"))
+	assert.FileExists(t, filepath.Join(fallback, OutboxFolder, "42", StatusFileName))
+
+	assert.ErrorIs(t, NewOutbox(dir, "").PutJobUpdate(job, "running"), ErrNoSpool)
+}
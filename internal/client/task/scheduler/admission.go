@@ -0,0 +1,66 @@
+package scheduler
+
+import "slices"
+
+// CheckAdmission checks if the tasks would be accepted right now without queueing them, they are checked
+// as a group like ScheduleGroup does. Returns the ids of the tasks that would be preempted for them
+func (s *Scheduler) CheckAdmission(tasks ...*Task) ([]string, error) {
+	for _, t := range tasks {
+		if err := IsValidTask(t); err != nil {
+			return nil, err
+		}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.checkAdmission(tasks)
+}
+
+// CheckRecurringAdmission checks if the recurring task would be accepted right now, only its first occurrence has to fit
+func (s *Scheduler) CheckRecurringAdmission(r *RecurringTask) ([]string, error) {
+	if err := IsValidRecurringTask(r); err != nil {
+		return nil, err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	start, ok := r.next(s.clock.Now())
+	if !ok {
+		return nil, ErrRecurrenceEnded
+	}
+
+	return s.checkAdmission([]*Task{r.occurrence(start)})
+}
+
+// checkAdmission must be called with the lock held
+func (s *Scheduler) checkAdmission(tasks []*Task) ([]string, error) {
+	if s.draining {
+		return nil, ErrSchedulerDraining
+	}
+
+	preempted := make([]string, 0)
+	for i, t := range tasks {
+		for _, r := range s.running {
+			if r.id == t.id {
+				return nil, ErrRunningTaskCantBeModified
+			}
+		}
+
+		// The earlier tasks of the group count as queued
+		others := append(s.others(t.id), tasks[:i]...)
+		victims, ok := s.makeRoomAmong(t, others)
+		if !ok {
+			return nil, ErrResourceSharingNotPossible
+		}
+
+		for _, v := range victims {
+			if !slices.Contains(preempted, v.id) {
+				preempted = append(preempted, v.id)
+			}
+		}
+	}
+
+	return preempted, nil
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAdmission(t *testing.T) {
+	log.Init(true)
+	noop := func(_ context.Context, _ interface{}) error { return nil }
+	start := time.Now().Add(time.Hour)
+
+	s := NewScheduler(1)
+	assert.NoError(t, s.Schedule(NewTask(start, start.Add(time.Hour), noop, nil).WithID("low").WithResource(SDRDevice1)))
+
+	// Free windows fit, nothing is queued by the check
+	preempted, err := s.CheckAdmission(NewTask(start.Add(2*time.Hour), start.Add(3*time.Hour), noop, nil).WithID("later").WithResource(SDRDevice1))
+	assert.NoError(t, err)
+	assert.Empty(t, preempted)
+	assert.Len(t, s.queue, 1)
+
+	// Equal priorities conflict, higher ones would preempt
+	_, err = s.CheckAdmission(NewTask(start, start.Add(time.Hour), noop, nil).WithID("same").WithResource(SDRDevice1))
+	assert.ErrorIs(t, err, ErrResourceSharingNotPossible)
+	preempted, err = s.CheckAdmission(NewTask(start, start.Add(time.Hour), noop, nil).WithID("urgent").WithResource(SDRDevice1).WithPriority(5))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low"}, preempted)
+	assert.Len(t, s.queue, 1)
+
+	// The tasks of a group compete with each other
+	a := NewTask(start.Add(2*time.Hour), start.Add(3*time.Hour), noop, nil).WithID("a").WithResource(SDRDevice1)
+	b := NewTask(start.Add(2*time.Hour), start.Add(3*time.Hour), noop, nil).WithID("b").WithResource(SDRDevice1)
+	_, err = s.CheckAdmission(a, b)
+	assert.ErrorIs(t, err, ErrResourceSharingNotPossible)
+
+	// Only the first occurrence of recurring tasks has to fit
+	r := NewRecurringTask(start, time.Hour, noop, nil).WithID("rec").WithInterval(24 * time.Hour).WithResource(SDRDevice1)
+	_, err = s.CheckRecurringAdmission(r)
+	assert.ErrorIs(t, err, ErrResourceSharingNotPossible)
+	r = NewRecurringTask(start.Add(90*time.Minute), time.Hour, noop, nil).WithID("rec").WithInterval(24 * time.Hour).WithResource(SDRDevice1)
+	_, err = s.CheckRecurringAdmission(r)
+	assert.NoError(t, err)
+
+	// Nothing is admitted while draining
+	s.Drain(0)
+	_, err = s.CheckAdmission(NewTask(start.Add(5*time.Hour), start.Add(6*time.Hour), noop, nil).WithID("drained"))
+	assert.ErrorIs(t, err, ErrSchedulerDraining)
+}
@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit bounds the search for the next match, impossible expressions like "0 0 31 2 *" never match
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// cronGapSamples is the number of upcoming matches minGap compares
+const cronGapSamples = 64
+
+// CronSchedule is a parsed standard 5 field cron expression (minute hour day-of-month month day-of-week)
+// All times are evaluated in UTC
+type CronSchedule struct {
+	expr    string
+	minutes uint64
+	hours   uint64
+	days    uint64
+	months  uint64
+	weekday uint64
+	// Restricted day fields are OR'ed like in cron(8)
+	daysRestricted    bool
+	weekdayRestricted bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 7},
+}
+
+// ParseCron parses expressions like "*/15 * * * *" or "0 3 * * 1-5"
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q needs %d fields, got %d", expr, len(cronFields), len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		var err error
+		if bits[i], err = parseCronField(f, cronFields[i]); err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+	}
+
+	c := &CronSchedule{
+		expr:              expr,
+		minutes:           bits[0],
+		hours:             bits[1],
+		days:              bits[2],
+		months:            bits[3],
+		weekday:           bits[4],
+		daysRestricted:    fields[2] != "*",
+		weekdayRestricted: fields[4] != "*",
+	}
+
+	// Sunday can be written as 0 or 7
+	if c.weekday&(1<<7) != 0 {
+		c.weekday |= 1
+	}
+
+	return c, nil
+}
+
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %s field %q", spec.name, part)
+			}
+			rangePart = part[:idx]
+		}
+
+		low, high := spec.min, spec.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value in %s field %q", spec.name, part)
+			}
+
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value in %s field %q", spec.name, part)
+				}
+			} else if step > 1 {
+				// "5/10" means every 10 starting at 5
+				high = spec.max
+			}
+		}
+
+		if low < spec.min || high > spec.max || low > high {
+			return 0, fmt.Errorf("%s field %q out of range %d-%d", spec.name, part, spec.min, spec.max)
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (c *CronSchedule) String() string {
+	return c.expr
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	dom := c.days&(1<<uint(t.Day())) != 0
+	dow := c.weekday&(1<<uint(t.Weekday())) != 0
+
+	if c.daysRestricted && c.weekdayRestricted {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+// Next returns the first matching time that is not before from, false if there is none
+func (c *CronSchedule) Next(from time.Time) (time.Time, bool) {
+	// Cron works on full minutes, round up
+	t := from.UTC()
+	if truncated := t.Truncate(time.Minute); !truncated.Equal(t) {
+		t = truncated.Add(time.Minute)
+	}
+
+	limit := t.Add(cronSearchLimit)
+	for t.Before(limit) {
+		if c.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if c.hours&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if c.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+// minGap returns the shortest gap between the upcoming matches starting at from, zero if there are less than two
+func (c *CronSchedule) minGap(from time.Time) time.Duration {
+	prev, ok := c.Next(from)
+	if !ok {
+		return 0
+	}
+
+	var gap time.Duration
+	for i := 1; i < cronGapSamples; i++ {
+		next, ok := c.Next(prev.Add(time.Minute))
+		if !ok {
+			break
+		}
+
+		if d := next.Sub(prev); gap == 0 || d < gap {
+			gap = d
+		}
+		prev = next
+	}
+
+	return gap
+}
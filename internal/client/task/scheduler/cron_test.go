@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "*/15 * * * *", "0 3 * * 1-5", "5,35 0-23/2 1 1,6 0", "30 4 * * 7"} {
+		_, err := ParseCron(expr)
+		assert.NoError(t, err, expr)
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2024, time.March, 15, 10, 7, 30, 0, time.UTC) // a friday
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", base, time.Date(2024, time.March, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", base, time.Date(2024, time.March, 16, 3, 0, 0, 0, time.UTC)},
+		// Monday to friday only, skips the weekend
+		{"0 3 * * 1-5", base, time.Date(2024, time.March, 18, 3, 0, 0, 0, time.UTC)},
+		// Sunday written as 7
+		{"30 4 * * 7", base, time.Date(2024, time.March, 17, 4, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", base, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		// Exact matches are returned as-is
+		{"0 12 * * *", time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC), time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)},
+		// Restricted day-of-month and day-of-week are OR'ed
+		{"0 0 20 * 6", base, time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		assert.NoError(t, err)
+
+		next, ok := c.Next(tt.from)
+		assert.True(t, ok, tt.expr)
+		assert.Equal(t, tt.want, next, tt.expr)
+	}
+
+	// There is no 31st of february
+	c, err := ParseCron("0 0 31 2 *")
+	assert.NoError(t, err)
+	_, ok := c.Next(base)
+	assert.False(t, ok)
+}
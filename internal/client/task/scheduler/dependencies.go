@@ -0,0 +1,266 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrDependencyNotFound = errors.New("task depends on a task that is not scheduled")
+	ErrDependencyCycle    = errors.New("task dependencies would form a cycle")
+	ErrDependencyFailed   = errors.New("a task this task depends on did not succeed")
+	ErrTaskAborted        = errors.New("task pre-execute function aborted the run")
+)
+
+// DependencyCondition defines when a dependent task may run
+type DependencyCondition int
+
+const (
+	// OnSuccess runs the dependent task only if the dependency returned without error
+	OnSuccess DependencyCondition = iota
+	// OnCompletion runs the dependent task once the dependency ended, whatever the outcome
+	OnCompletion
+)
+
+// Dependency is an edge to a task that has to end before the dependent task starts
+type Dependency struct {
+	ID        string              `json:"id"`
+	Condition DependencyCondition `json:"condition"`
+}
+
+// Result is the outcome of a task that ended, it is kept until all dependents ended
+type Result struct {
+	Err    error
+	Output interface{}
+}
+
+type taskContextKey struct{}
+
+// WithDependency lets the task start only after the task with the given id ended as required by the condition.
+// The task still does not start before its StartTime and is dropped if its EndTime passes while waiting.
+func (t *Task) WithDependency(id string, condition DependencyCondition) *Task {
+	t.dependencies = append(t.dependencies, Dependency{ID: id, Condition: condition})
+	return t
+}
+
+// Dependencies returns the dependency edges of the task
+func (t *Task) Dependencies() []Dependency {
+	return t.dependencies
+}
+
+// SetOutput stores the output of the running task, it is handed to the tasks that depend on it
+func SetOutput(ctx context.Context, output interface{}) {
+	if t, ok := ctx.Value(taskContextKey{}).(*Task); ok {
+		t.output = output
+	}
+}
+
+// Inputs returns the outputs of the dependencies of the running task by task id
+func Inputs(ctx context.Context) map[string]interface{} {
+	if t, ok := ctx.Value(taskContextKey{}).(*Task); ok {
+		return t.inputs
+	}
+
+	return nil
+}
+
+// find returns the queued or running task with the given id, must be called with the lock held
+func (s *Scheduler) find(id string) *Task {
+	for _, t := range s.running {
+		if t.id == id {
+			return t
+		}
+	}
+	for _, t := range s.queue {
+		if t.id == id {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// checkDependencies makes sure all dependencies are known and do not lead back to the task
+// must be called with the lock held
+func (s *Scheduler) checkDependencies(newTask *Task) error {
+	for _, dep := range newTask.dependencies {
+		if dep.ID == newTask.id {
+			return ErrDependencyCycle
+		}
+
+		upstream := s.find(dep.ID)
+		if upstream == nil {
+			if _, ok := s.results[dep.ID]; !ok {
+				return ErrDependencyNotFound
+			}
+			continue
+		}
+
+		// Only a modified task can already have dependents
+		if s.dependsOn(upstream, newTask.id, map[string]bool{}) {
+			return ErrDependencyCycle
+		}
+	}
+
+	return nil
+}
+
+// dependsOn checks if the task transitively depends on the task with the given id
+func (s *Scheduler) dependsOn(t *Task, id string, seen map[string]bool) bool {
+	for _, dep := range t.dependencies {
+		if dep.ID == id {
+			return true
+		}
+
+		if seen[dep.ID] {
+			continue
+		}
+		seen[dep.ID] = true
+
+		if upstream := s.find(dep.ID); upstream != nil && s.dependsOn(upstream, id, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dependenciesMet returns true if the task can start, an error if it never will
+// must be called with the lock held
+func (s *Scheduler) dependenciesMet(t *Task) (bool, error) {
+	for _, dep := range t.dependencies {
+		// Still queued, waiting or running
+		result, ok := s.results[dep.ID]
+		if !ok {
+			return false, nil
+		}
+
+		if dep.Condition == OnSuccess && result.Err != nil {
+			log.Info("dependency did not succeed", zap.String("id", t.id), zap.String("dependency", dep.ID), zap.Error(result.Err))
+			return false, ErrDependencyFailed
+		}
+	}
+
+	return true, nil
+}
+
+// complete stores the result of a task for its dependents and the history and wakes the run loop,
+// which drops the result again if nothing depends on it. Must be called with the lock held
+func (s *Scheduler) complete(t *Task, result Result) {
+	s.results[t.id] = result
+	s.remember(t, result.Err, stateOf(result.Err))
+	s.notify()
+}
+
+// pruneResults drops the results no queued or running task depends on anymore, running ones might be retried.
+// Must be called with the lock held
+func (s *Scheduler) pruneResults() {
+	for id := range s.results {
+		dependent := func(t *Task) bool {
+			return slices.ContainsFunc(t.dependencies, func(dep Dependency) bool { return dep.ID == id })
+		}
+		needed := slices.ContainsFunc(s.queue, dependent) || slices.ContainsFunc(s.running, dependent)
+
+		if !needed {
+			delete(s.results, id)
+		}
+	}
+}
+
+// inputsOf collects the outputs of the dependencies, must be called with the lock held
+func (s *Scheduler) inputsOf(t *Task) map[string]interface{} {
+	if len(t.dependencies) == 0 {
+		return nil
+	}
+
+	inputs := make(map[string]interface{}, len(t.dependencies))
+	for _, dep := range t.dependencies {
+		if result, ok := s.results[dep.ID]; ok && result.Output != nil {
+			inputs[dep.ID] = result.Output
+		}
+	}
+
+	return inputs
+}
+
+// ScheduleGroup schedules a set of tasks at once, e.g. the steps of a composite job.
+// Dependencies have to be listed before their dependents. If one task can not be scheduled,
+// the tasks added by this call are removed again (preemptions they caused are not undone).
+// Tasks that already exist unchanged are skipped, ErrTaskAlreadyExists is only returned if all of them existed.
+func (s *Scheduler) ScheduleGroup(tasks ...*Task) error {
+	for _, t := range tasks {
+		if err := IsValidTask(t); err != nil {
+			return err
+		}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	added := make([]*Task, 0, len(tasks))
+	changed := false
+	for _, t := range tasks {
+		existed := s.find(t.id) != nil
+
+		err := s.submit(t)
+		if errors.Is(err, ErrTaskAlreadyExists) || errors.Is(err, ErrTaskAlreadyRunning) {
+			continue
+		}
+
+		if err != nil {
+			for _, a := range added {
+				if idx := slices.Index(s.queue, a); idx >= 0 {
+					s.removeTaskFromQueue(idx)
+				}
+			}
+			return err
+		}
+
+		changed = true
+		if !existed {
+			added = append(added, t)
+		}
+	}
+
+	if !changed {
+		return ErrTaskAlreadyExists
+	}
+
+	return nil
+}
+
+// sortByDependencies orders the records so dependencies come before their dependents
+func sortByDependencies(records []TaskRecord) []TaskRecord {
+	byID := make(map[string]TaskRecord, len(records))
+	for _, r := range records {
+		byID[r.ID] = r
+	}
+
+	sorted := make([]TaskRecord, 0, len(records))
+	visited := make(map[string]bool, len(records))
+
+	var visit func(r TaskRecord)
+	visit = func(r TaskRecord) {
+		if visited[r.ID] {
+			return
+		}
+		visited[r.ID] = true
+
+		for _, dep := range r.Dependencies {
+			if upstream, ok := byID[dep.ID]; ok {
+				visit(upstream)
+			}
+		}
+		sorted = append(sorted, r)
+	}
+
+	for _, r := range records {
+		visit(r)
+	}
+
+	return sorted
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDependencyValidation(t *testing.T) {
+	log.Init(true)
+	noop := func(_ context.Context, _ interface{}) error { return nil }
+	start := time.Now().Add(time.Hour)
+
+	s := NewScheduler(1)
+	assert.ErrorIs(t, s.Schedule(NewTask(start, start.Add(time.Minute), noop, nil).WithID("b").WithDependency("a", OnSuccess)), ErrDependencyNotFound)
+	assert.ErrorIs(t, s.Schedule(NewTask(start, start.Add(time.Minute), noop, nil).WithID("a").WithDependency("a", OnSuccess)), ErrDependencyCycle)
+
+	assert.NoError(t, s.Schedule(NewTask(start, start.Add(time.Minute), noop, nil).WithID("a")))
+	assert.NoError(t, s.Schedule(NewTask(start, start.Add(time.Minute), noop, nil).WithID("b").WithDependency("a", OnSuccess)))
+
+	// Modifying a to depend on b would close the cycle
+	assert.ErrorIs(t, s.Schedule(NewTask(start, start.Add(time.Minute), noop, nil).WithID("a").WithDependency("b", OnCompletion)), ErrDependencyCycle)
+
+	// A failing group is rolled back
+	err := s.ScheduleGroup(
+		NewTask(start, start.Add(time.Minute), noop, nil).WithID("c"),
+		NewTask(start, start.Add(time.Minute), noop, nil).WithID("d").WithDependency("missing", OnSuccess),
+	)
+	assert.ErrorIs(t, err, ErrDependencyNotFound)
+	assert.Len(t, s.queue, 2)
+}
+
+func TestDependencyChain(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(2)
+	go s.Run()
+	defer s.Shutdown()
+
+	now := time.Now()
+	end := now.Add(time.Minute)
+	order := make(chan string, 4)
+	dropped := make(chan error, 1)
+
+	capture := NewTask(now, end, func(ctx context.Context, _ interface{}) error {
+		SetOutput(ctx, "/tmp/capture.bits")
+		order <- "capture"
+		return nil
+	}, nil).WithID("capture").WithResource(SDRDevice1)
+
+	process := NewTask(now, end, func(ctx context.Context, _ interface{}) error {
+		order <- "process:" + Inputs(ctx)["capture"].(string)
+		return errors.New("decoder crashed")
+	}, nil).WithID("process").WithDependency("capture", OnSuccess)
+
+	upload := NewTask(now, end, func(_ context.Context, _ interface{}) error {
+		order <- "upload"
+		return nil
+	}, nil).WithID("upload").WithDependency("process", OnSuccess)
+	upload.PostExecute = func(err error) { dropped <- err }
+
+	reboot := NewTask(now, end, func(_ context.Context, _ interface{}) error {
+		order <- "reboot"
+		return nil
+	}, nil).WithID("reboot").WithDependency("upload", OnCompletion)
+
+	assert.NoError(t, s.ScheduleGroup(capture, process, upload, reboot))
+
+	expect := []string{"capture", "process:/tmp/capture.bits", "reboot"}
+	for _, e := range expect {
+		select {
+		case got := <-order:
+			assert.Equal(t, e, got)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %s", e)
+		}
+	}
+
+	// The upload never ran as processing failed
+	assert.ErrorIs(t, <-dropped, ErrDependencyFailed)
+
+	// All results were consumed
+	assert.Eventually(t, func() bool {
+		s.m.RLock()
+		defer s.m.RUnlock()
+		return len(s.results) == 0
+	}, time.Second, 10*time.Millisecond)
+}
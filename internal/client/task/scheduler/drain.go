@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"go.uber.org/zap"
+)
+
+var ErrSchedulerDraining = errors.New("scheduler is draining and does not accept tasks")
+
+// Drain stops accepting and starting tasks and waits until the running tasks ended, those still running
+// after the timeout are cancelled. Queued tasks are kept in the journal, the ones that would have started
+// during the drain window are returned. The scheduler still has to be shut down afterwards.
+func (s *Scheduler) Drain(timeout time.Duration) []TaskInfo {
+	s.m.Lock()
+	s.draining = true
+	now := s.clock.Now()
+	s.drainDeadline = now.Add(timeout)
+
+	held := make([]TaskInfo, 0)
+	for _, t := range s.queue {
+		if t.StartTime.Before(s.drainDeadline) {
+			held = append(held, t.info(StateQueued, now))
+		}
+	}
+	running := len(s.running)
+	s.m.Unlock()
+
+	log.Info("draining scheduler", zap.Int("running", running), zap.Int("held", len(held)), zap.Duration("timeout", timeout))
+
+	// No new workers are spawned from now on
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	timer := s.clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		log.Info("all running tasks ended, scheduler drained")
+	case <-timer.C():
+		s.m.RLock()
+		for _, t := range s.running {
+			log.Warn("task did not end within the drain timeout, cancelling", zap.String("id", t.id))
+			t.cancel(ErrSchedulerDraining)
+		}
+		s.m.RUnlock()
+		<-done
+	}
+
+	sortInfos(held)
+	return held
+}
+
+// Draining returns true once Drain was called
+func (s *Scheduler) Draining() bool {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.draining
+}
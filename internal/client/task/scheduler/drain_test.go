@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/clock"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain(t *testing.T) {
+	log.Init(true)
+	clk := clock.NewFake(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC))
+	journal := NewFileJournal(filepath.Join(t.TempDir(), "journal.json"))
+	s := NewScheduler(2).WithClock(clk).WithJournal(journal)
+	go s.Run()
+	defer s.Shutdown()
+
+	now := clk.Now()
+	noop := func(_ context.Context, _ interface{}) error { return nil }
+
+	// One task ends on its own during the drain, the other one has to be cancelled
+	release := make(chan struct{})
+	upload := NewTask(now, now.Add(time.Hour), func(_ context.Context, _ interface{}) error {
+		<-release
+		return nil
+	}, nil).WithID("upload")
+	cancelled := make(chan error, 1)
+	capture := NewTask(now, now.Add(time.Hour), func(ctx context.Context, _ interface{}) error {
+		<-ctx.Done()
+		cancelled <- context.Cause(ctx)
+		return ctx.Err()
+	}, nil).WithID("capture")
+	assert.NoError(t, s.Schedule(upload))
+	assert.NoError(t, s.Schedule(capture))
+	assert.Eventually(t, func() bool { return len(s.Snapshot().Running) == 2 }, time.Second, time.Millisecond)
+
+	// Only the first queued task would start during the drain window
+	soon := NewTask(now.Add(30*time.Second), now.Add(time.Hour), noop, nil).WithID("soon").WithPayload(json.RawMessage(`{}`))
+	later := NewTask(now.Add(2*time.Hour), now.Add(3*time.Hour), noop, nil).WithID("later").WithPayload(json.RawMessage(`{}`))
+	assert.NoError(t, s.Schedule(soon))
+	assert.NoError(t, s.Schedule(later))
+
+	drained := make(chan []TaskInfo, 1)
+	go func() { drained <- s.Drain(time.Minute) }()
+	assert.Eventually(t, s.Draining, time.Second, time.Millisecond)
+
+	// No new tasks are accepted
+	assert.ErrorIs(t, s.Schedule(NewTask(now, now.Add(time.Hour), noop, nil)), ErrSchedulerDraining)
+	snap := s.Snapshot()
+	assert.True(t, snap.Draining)
+	assert.Equal(t, now.Add(time.Minute), snap.DrainDeadline)
+
+	close(release)
+	assert.Eventually(t, func() bool { return len(s.Snapshot().Running) == 1 }, time.Second, time.Millisecond)
+
+	// The queued task is not started during the drain, the capture is cancelled at the deadline
+	advanceUntil(t, clk, 10*time.Second, func() bool { return len(cancelled) == 1 })
+	assert.ErrorIs(t, <-cancelled, ErrSchedulerDraining)
+	assert.False(t, clk.Now().Before(now.Add(time.Minute)))
+
+	held := <-drained
+	assert.Len(t, held, 1)
+	assert.Equal(t, "soon", held[0].ID)
+	assert.False(t, s.HasRunningJob())
+
+	// Both queued tasks survive in the journal
+	records, err := journal.Load()
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+}
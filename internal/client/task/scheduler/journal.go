@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/file"
+)
+
+// TaskRecord is the persisted form of a task, the command itself can not be stored
+// so the Payload has to contain everything that is needed to rebuild the task
+type TaskRecord struct {
+	StartTime time.Time       `json:"start_time"`
+	EndTime   time.Time       `json:"end_time"`
+	ID        string          `json:"id"`
+	Resources ResourceClaims  `json:"resources,omitempty"`
+	Priority  int             `json:"priority,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	// Running is true if the task was executing when the record was written
+	Running bool `json:"running"`
+	// Recurring marks a recurring definition, the EndTime holds its optional end date
+	Recurring bool `json:"recurring,omitempty"`
+	// Parent is the id of the recurring definition an occurrence was expanded from
+	Parent string `json:"parent,omitempty"`
+	// Dependencies of the task, dependencies are restored before their dependents
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+// Journal stores the scheduler state so it can be restored after a restart
+type Journal interface {
+	Load() ([]TaskRecord, error)
+	Store([]TaskRecord) error
+}
+
+// FileJournal is a Journal that keeps the records in a single json file
+type FileJournal struct {
+	m    sync.Mutex
+	path string
+}
+
+func NewFileJournal(path string) *FileJournal {
+	return &FileJournal{path: path}
+}
+
+// Load reads all records, a missing journal is not an error
+func (j *FileJournal) Load() ([]TaskRecord, error) {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	data, err := os.ReadFile(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []TaskRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Store replaces the journal contents, the file is swapped atomically so a crash never leaves a partial journal
+func (j *FileJournal) Store(records []TaskRecord) error {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := j.path + ".tmp"
+	f, err := file.CreateFileP(tmpPath, 0750)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Clean(j.path))
+}
+
+// record converts a task into its persisted form
+func (t *Task) record(running bool) TaskRecord {
+	r := TaskRecord{
+		ID:        t.id,
+		StartTime: t.StartTime,
+		EndTime:   t.EndTime,
+		Resources: t.resources.sorted(),
+		Priority:  t.Priority,
+		Payload:   t.payload,
+		Running:   running,
+
+		Dependencies: t.dependencies,
+	}
+
+	if t.parent != nil {
+		r.Parent = t.parent.id
+	}
+
+	return r
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournalRestore(t *testing.T) {
+	log.Init(true)
+	journal := NewFileJournal(filepath.Join(t.TempDir(), "journal.json"))
+
+	// Loading a missing journal yields no records
+	records, err := journal.Load()
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	noop := func(_ context.Context, _ interface{}) error { return nil }
+	start := time.Now().Add(time.Hour).UTC()
+
+	s := NewScheduler(1).WithJournal(journal)
+	queued := NewTask(start, start.Add(time.Minute), noop, nil).
+		WithID("queued").
+		WithResource(SDRDevice1).
+		WithPayload(json.RawMessage(`{"name":"queued"}`))
+	assert.NoError(t, s.Schedule(queued))
+
+	// Tasks without payload are not journaled
+	assert.NoError(t, s.Schedule(NewTask(start, start.Add(time.Minute), noop, nil)))
+
+	records, err = journal.Load()
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "queued", records[0].ID)
+	assert.Equal(t, ResourceClaims{SDRDevice1}, records[0].Resources)
+
+	// Simulate a crash while a task was running
+	records = append(records, TaskRecord{
+		ID:        "running",
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+		Payload:   json.RawMessage(`{"name":"running"}`),
+		Running:   true,
+	})
+	assert.NoError(t, journal.Store(records))
+
+	restored := NewScheduler(1).WithJournal(journal)
+	interrupted, err := restored.Restore(func(r TaskRecord) error {
+		return restored.Schedule(NewTask(r.StartTime, r.EndTime, noop, nil).
+			WithID(r.ID).
+			WithResource(r.Resources...).
+			WithPayload(r.Payload))
+	})
+	assert.NoError(t, err)
+	assert.Len(t, interrupted, 1)
+	assert.Equal(t, "running", interrupted[0].ID)
+
+	// The queued task is back and still claims its resources
+	assert.Len(t, restored.queue, 1)
+	assert.ErrorIs(t, restored.Schedule(NewTask(start, start.Add(time.Minute), noop, nil).WithResource(SDRDevice1)), ErrResourceSharingNotPossible)
+
+	// The interrupted task was dropped from the journal
+	records, err = journal.Load()
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.False(t, records[0].Running)
+}
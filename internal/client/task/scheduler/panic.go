@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// The crash log is moved aside once it grows beyond this size
+	MaxCrashLogSize = 1024 * 1024
+)
+
+// PanicError is the result of a task whose command panicked
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
+
+func (e *PanicError) Is(err error) bool {
+	_, ok := err.(*PanicError)
+	return ok
+}
+
+// WithCrashLog appends the stack traces of panicking tasks to the given file
+func (s *Scheduler) WithCrashLog(path string) *Scheduler {
+	s.crashLog = path
+	return s
+}
+
+// call runs the command of the task, a panic only ends this task with a PanicError
+func (s *Scheduler) call(ctx context.Context, task *Task) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			panicErr := &PanicError{Value: v, Stack: debug.Stack()}
+			log.Error("task panicked", zap.String("id", task.id), zap.Any("panic", v), zap.ByteString("stack", panicErr.Stack))
+			s.writeCrashLog(task, panicErr)
+			err = panicErr
+		}
+	}()
+
+	return task.Command(ctx, task.Argument)
+}
+
+// writeCrashLog keeps the panic on disk, the service log might be gone after a reboot
+func (s *Scheduler) writeCrashLog(task *Task, panicErr *PanicError) {
+	if len(s.crashLog) == 0 {
+		return
+	}
+
+	if info, err := os.Stat(s.crashLog); err == nil && info.Size() > MaxCrashLogSize {
+		_ = os.Rename(s.crashLog, s.crashLog+".old")
+	}
+
+	f, err := os.OpenFile(s.crashLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		log.Error("could not open crash log", zap.String("file", s.crashLog), zap.Error(err))
+		return
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s task %s (attempt %d) panicked: %v\n%s\n",
+		s.clock.Now().UTC().Format("2006-01-02T15:04:05Z"), task.id, task.attempt, panicErr.Value, panicErr.Stack)
+	if err != nil {
+		log.Error("could not write crash log", zap.String("file", s.crashLog), zap.Error(err))
+	}
+}
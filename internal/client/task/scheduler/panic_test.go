@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskPanic(t *testing.T) {
+	log.Init(true)
+	crashLog := filepath.Join(t.TempDir(), "crash.log")
+	s := NewScheduler(2).WithCrashLog(crashLog)
+	go s.Run()
+	defer s.Shutdown()
+
+	// A capture that keeps running while the other task crashes
+	release := make(chan struct{})
+	captured := make(chan error, 1)
+	capture := NewTask(time.Now(), time.Now().Add(time.Minute), func(ctx context.Context, _ interface{}) error {
+		<-release
+		return nil
+	}, nil).WithID("capture")
+	capture.PostExecute = func(err error) { captured <- err }
+	assert.NoError(t, s.Schedule(capture))
+
+	crashed := make(chan error, 1)
+	crash := NewTask(time.Now(), time.Now().Add(time.Minute), func(ctx context.Context, _ interface{}) error {
+		var counts map[string]int
+		counts["frames"]++
+		return nil
+	}, nil).WithID("crash")
+	crash.PostExecute = func(err error) { crashed <- err }
+	assert.NoError(t, s.Schedule(crash))
+
+	err := <-crashed
+	var panicErr *PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Contains(t, string(panicErr.Stack), "panic_test.go")
+
+	// The other task is not affected
+	close(release)
+	assert.NoError(t, <-captured)
+
+	content, err := os.ReadFile(crashLog)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "task crash (attempt 1) panicked: assignment to entry in nil map")
+
+	assert.Eventually(t, func() bool { return len(s.Snapshot().History) == 2 }, time.Second, 10*time.Millisecond)
+	for _, info := range s.Snapshot().History {
+		if info.ID == "crash" {
+			assert.Equal(t, StateFailed, info.State)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"slices"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"go.uber.org/zap"
+)
+
+// competes checks if the task claims any of the given resources during the window of other
+func (t *Task) competes(other *Task) bool {
+	if !t.Overlaps(other) {
+		return false
+	}
+
+	for _, claim := range t.resources {
+		for _, wanted := range other.resources {
+			if claim.Resource == wanted.Resource {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// makeRoom checks if newTask fits and otherwise selects the lower priority tasks that have to be preempted for it.
+// As few tasks as possible are chosen, lowest priority first. Returns false if even preempting all of them does not suffice.
+// must be called with the lock held
+func (s *Scheduler) makeRoom(newTask *Task) ([]*Task, bool) {
+	return s.makeRoomAmong(newTask, s.others(newTask.id))
+}
+
+// makeRoomAmong is makeRoom for the given running and queued tasks, must be called with the lock held
+func (s *Scheduler) makeRoomAmong(newTask *Task, others []*Task) ([]*Task, bool) {
+	if s.capacities.fits(newTask, others) {
+		return nil, true
+	}
+
+	kept := make([]*Task, 0, len(others))
+	candidates := make([]*Task, 0)
+	for _, t := range others {
+		if t.Priority < newTask.Priority && t.competes(newTask) {
+			candidates = append(candidates, t)
+		} else {
+			kept = append(kept, t)
+		}
+	}
+
+	// Lowest priority first, the latest start first among equals so work that started earlier survives
+	slices.SortStableFunc(candidates, func(a, b *Task) int {
+		if a.Priority != b.Priority {
+			return a.Priority - b.Priority
+		}
+		return b.StartTime.Compare(a.StartTime)
+	})
+
+	// Evict until the task fits
+	n := 0
+	for !s.capacities.fits(newTask, append(slices.Clone(kept), candidates[n:]...)) {
+		if n == len(candidates) {
+			return nil, false
+		}
+		n++
+	}
+
+	// Some of the earlier evictions might have become unnecessary, keep the most important ones
+	victims := make([]*Task, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		others := append(slices.Clone(kept), candidates[i])
+		if s.capacities.fits(newTask, append(others, candidates[n:]...)) {
+			kept = append(kept, candidates[i])
+			continue
+		}
+		victims = append(victims, candidates[i])
+	}
+
+	return victims, true
+}
+
+// preempt removes queued victims and cancels running ones, must be called with the lock held
+// Running victims keep their resources until they returned, so the new task is held back until then
+func (s *Scheduler) preempt(victims []*Task, by *Task) {
+	for _, victim := range victims {
+		log.Warn("preempting task",
+			zap.String("id", victim.id),
+			zap.Int("priority", victim.Priority),
+			zap.String("by", by.id),
+			zap.Int("byPriority", by.Priority))
+
+		if idx := slices.Index(s.queue, victim); idx >= 0 {
+			s.removeTaskFromQueue(idx)
+			s.complete(victim, Result{Err: ErrTaskPreempted})
+			if victim.PostExecute != nil {
+				go victim.PostExecute(ErrTaskPreempted)
+			}
+
+			// Only this occurrence is preempted, the recurring definition stays
+			if p := victim.parent; p != nil && s.recurring[p.id] == p {
+				s.expandRecurring(p, victim.StartTime)
+			}
+			continue
+		}
+
+		// The task observes the preemption through context.Cause
+		victim.preempted = true
+		victim.cancel(ErrTaskPreempted)
+	}
+}
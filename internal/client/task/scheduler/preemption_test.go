@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreemptQueued(t *testing.T) {
+	log.Init(true)
+	noop := func(_ context.Context, _ interface{}) error { return nil }
+	start := time.Now().Add(time.Hour)
+
+	s := NewScheduler(1)
+
+	preempted := make(chan error, 2)
+	low := NewTask(start, start.Add(time.Hour), noop, nil).WithID("low").WithResource(SDRDevice1)
+	low.PostExecute = func(err error) { preempted <- err }
+	lower := NewTask(start.Add(2*time.Hour), start.Add(3*time.Hour), noop, nil).WithID("lower").WithResource(SDRDevice1).WithPriority(-1)
+	lower.PostExecute = func(err error) { preempted <- err }
+	assert.NoError(t, s.Schedule(low))
+	assert.NoError(t, s.Schedule(lower))
+
+	// Equal priorities still conflict
+	assert.ErrorIs(t, s.Schedule(NewTask(start, start.Add(time.Hour), noop, nil).WithResource(SDRDevice1)), ErrResourceSharingNotPossible)
+
+	// The urgent task only evicts the overlapping task
+	urgent := NewTask(start.Add(30*time.Minute), start.Add(90*time.Minute), noop, nil).WithID("urgent").WithResource(SDRDevice1).WithPriority(10)
+	assert.NoError(t, s.Schedule(urgent))
+	assert.ErrorIs(t, <-preempted, ErrTaskPreempted)
+	assert.Len(t, s.queue, 2)
+	for _, task := range s.queue {
+		assert.NotEqual(t, "low", task.id)
+	}
+
+	// Tasks without competing resources are never preempted
+	assert.NoError(t, s.Schedule(NewTask(start, start.Add(time.Hour), noop, nil).WithID("free")))
+	assert.NoError(t, s.Schedule(NewTask(start, start.Add(time.Hour), noop, nil).WithResource(FullCPU).WithPriority(20)))
+	assert.Len(t, s.queue, 4)
+}
+
+func TestPreemptMinimal(t *testing.T) {
+	log.Init(true)
+	noop := func(_ context.Context, _ interface{}) error { return nil }
+	start := time.Now().Add(time.Hour)
+
+	s := NewScheduler(1).WithCapacity(SDRDevice, 2)
+
+	// Two captures use both SDRs, the urgent capture only needs one of them
+	assert.NoError(t, s.Schedule(NewTask(start, start.Add(time.Hour), noop, nil).WithID("a").WithResource(SDRDevice1).WithPriority(1)))
+	assert.NoError(t, s.Schedule(NewTask(start, start.Add(time.Hour), noop, nil).WithID("b").WithResource(SDRDevice1)))
+	assert.NoError(t, s.Schedule(NewTask(start, start.Add(time.Hour), noop, nil).WithID("urgent").WithResource(SDRDevice1).WithPriority(5)))
+
+	ids := make([]string, 0)
+	for _, task := range s.queue {
+		ids = append(ids, task.id)
+	}
+	assert.ElementsMatch(t, []string{"a", "urgent"}, ids)
+}
+
+func TestPreemptRunning(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(2)
+	go s.Run()
+	defer s.Shutdown()
+
+	cause := make(chan error, 1)
+	low := NewTask(time.Now(), time.Now().Add(time.Hour), func(ctx context.Context, _ interface{}) error {
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return nil
+	}, nil).WithID("low").WithResource(SDRDevice1)
+	done := make(chan error, 1)
+	low.PostExecute = func(err error) { done <- err }
+	assert.NoError(t, s.Schedule(low))
+
+	// Wait until the capture runs
+	assert.Eventually(t, s.HasRunningJob, time.Second, 10*time.Millisecond)
+
+	started := make(chan struct{})
+	urgent := NewTask(time.Now(), time.Now().Add(time.Hour), func(_ context.Context, _ interface{}) error {
+		close(started)
+		return nil
+	}, nil).WithResource(SDRDevice1).WithPriority(1)
+	assert.NoError(t, s.Schedule(urgent))
+
+	select {
+	case err := <-cause:
+		assert.ErrorIs(t, err, ErrTaskPreempted)
+	case <-time.After(time.Second):
+		t.Fatal("running task was not preempted")
+	}
+
+	// The task reports the preemption itself, the hook only sees what it returned
+	assert.NoError(t, <-done)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("urgent task did not start after the preempted task returned")
+	}
+
+	history := s.Snapshot().History
+	if assert.NotEmpty(t, history) {
+		assert.Equal(t, "low", history[0].ID)
+		assert.Equal(t, StatePreempted, history[0].State)
+	}
+}
@@ -0,0 +1,315 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// How many conflicting occurrences are skipped before the next one is given up
+	MaxRecurringSkips = 100
+)
+
+var (
+	ErrRecurrenceInvalid = errors.New("recurring task needs either an interval or a cron schedule")
+	ErrRecurrenceOverlap = errors.New("recurring task occurrences would overlap each other")
+	ErrRecurrenceEnded   = errors.New("recurring task has no further occurrences")
+)
+
+// RecurringTask is a task definition that gets expanded into concrete occurrences.
+// Only the next occurrence is queued, the following one is expanded as soon as it starts.
+type RecurringTask struct {
+	// First possible occurrence, also the anchor for interval based schedules
+	StartTime time.Time
+	// Optional end date, no occurrence starts after it
+	Until time.Time
+	// Either Interval or Cron has to be set
+	Interval time.Duration
+	Cron     *CronSchedule
+	// The duration of each occurrence
+	Duration time.Duration
+
+	Argument    interface{}
+	Command     JobFunction
+	PreExecute  func() bool
+	PostExecute func(error)
+	// Passed on to every occurrence
+	Priority     int
+	retry        *RetryPolicy
+	timeCritical bool
+	resources    ResourceClaims
+	id           string
+	payload      json.RawMessage
+}
+
+func NewRecurringTask(startTime time.Time, duration time.Duration, command func(context.Context, interface{}) error, arg interface{}) *RecurringTask {
+	return &RecurringTask{
+		StartTime: startTime,
+		Duration:  duration,
+		Command:   command,
+		Argument:  arg,
+	}
+}
+
+func (r *RecurringTask) WithInterval(interval time.Duration) *RecurringTask {
+	r.Interval = interval
+	return r
+}
+
+func (r *RecurringTask) WithCron(cron *CronSchedule) *RecurringTask {
+	r.Cron = cron
+	return r
+}
+
+func (r *RecurringTask) WithUntil(until time.Time) *RecurringTask {
+	r.Until = until
+	return r
+}
+
+func (r *RecurringTask) WithPriority(priority int) *RecurringTask {
+	r.Priority = priority
+	return r
+}
+
+func (r *RecurringTask) WithRetry(policy RetryPolicy) *RecurringTask {
+	r.retry = &policy
+	return r
+}
+
+// WithTimeCritical lets the occurrences only start once the time is synchronized
+func (r *RecurringTask) WithTimeCritical() *RecurringTask {
+	r.timeCritical = true
+	return r
+}
+
+func (r *RecurringTask) WithResource(claims ...ResourceClaim) *RecurringTask {
+	r.resources = append(r.resources, claims...)
+	return r
+}
+
+func (r *RecurringTask) WithID(id string) *RecurringTask {
+	if len(id) == 0 {
+		log.Panic("empty task id in scheduler will break it, panic")
+	}
+
+	r.id = id
+	return r
+}
+
+func (r *RecurringTask) WithPayload(payload json.RawMessage) *RecurringTask {
+	r.payload = payload
+	return r
+}
+
+// ID returns the id of the definition, occurrences use OccurrenceID
+func (r *RecurringTask) ID() string {
+	return r.id
+}
+
+// Equals checks if the user-supplied definition parameters are the same
+func (r *RecurringTask) Equals(other *RecurringTask) bool {
+	if r == nil || other == nil {
+		return r == other
+	}
+
+	cronEqual := (r.Cron == nil && other.Cron == nil) ||
+		(r.Cron != nil && other.Cron != nil && r.Cron.String() == other.Cron.String())
+
+	return r.id == other.id &&
+		r.StartTime.Equal(other.StartTime) &&
+		r.Until.Equal(other.Until) &&
+		r.Interval == other.Interval &&
+		r.Duration == other.Duration &&
+		r.Priority == other.Priority &&
+		cronEqual
+}
+
+// OccurrenceID returns the id of the occurrence starting at start, each definition has its own namespace
+func OccurrenceID(id string, start time.Time) string {
+	return fmt.Sprintf("%s@%d", id, start.Unix())
+}
+
+func IsValidRecurringTask(r *RecurringTask) error {
+	if len(r.id) == 0 {
+		return ErrTaskIDInvalid
+	}
+
+	if r.Command == nil {
+		return ErrTaskInvalidHandler
+	}
+
+	// Exactly one schedule type
+	if (r.Interval > 0) == (r.Cron != nil) {
+		return ErrRecurrenceInvalid
+	}
+
+	if r.Duration <= 0 {
+		return ErrTaskTimesInvalid
+	}
+
+	if r.Duration > MaxTaskDuration {
+		return ErrTaskMaxDurationExceeded
+	}
+
+	// Occurrences touching each other would always conflict
+	if r.Interval > 0 && r.Duration >= r.Interval {
+		return ErrRecurrenceOverlap
+	}
+
+	if r.Cron != nil {
+		if gap := r.Cron.minGap(r.StartTime); gap > 0 && r.Duration >= gap {
+			return ErrRecurrenceOverlap
+		}
+	}
+
+	if !r.Until.IsZero() && r.Until.Before(r.StartTime) {
+		return ErrTaskTimesInvalid
+	}
+
+	return nil
+}
+
+// next returns the first occurrence start that is not before from
+func (r *RecurringTask) next(from time.Time) (time.Time, bool) {
+	if from.Before(r.StartTime) {
+		from = r.StartTime
+	}
+
+	var start time.Time
+	if r.Cron != nil {
+		var ok bool
+		if start, ok = r.Cron.Next(from); !ok {
+			return time.Time{}, false
+		}
+	} else {
+		// Round up to the next multiple of the interval
+		n := (from.Sub(r.StartTime) + r.Interval - 1) / r.Interval
+		start = r.StartTime.Add(n * r.Interval)
+	}
+
+	if !r.Until.IsZero() && start.After(r.Until) {
+		return time.Time{}, false
+	}
+
+	return start, true
+}
+
+// occurrence creates the concrete task for the given start time
+func (r *RecurringTask) occurrence(start time.Time) *Task {
+	end := start.Add(r.Duration)
+	if !r.Until.IsZero() && end.After(r.Until) {
+		end = r.Until
+	}
+
+	t := NewTask(start, end, r.Command, r.Argument).
+		WithID(OccurrenceID(r.id, start)).
+		WithResource(r.resources...).
+		WithPriority(r.Priority).
+		WithPayload(r.payload)
+	t.PreExecute = r.PreExecute
+	t.PostExecute = r.PostExecute
+	t.parent = r
+	t.retry = r.retry
+	t.timeCritical = r.timeCritical
+
+	return t
+}
+
+// record converts the definition into its persisted form
+func (r *RecurringTask) record() TaskRecord {
+	return TaskRecord{
+		ID:        r.id,
+		StartTime: r.StartTime,
+		EndTime:   r.Until,
+		Resources: r.resources.sorted(),
+		Priority:  r.Priority,
+		Payload:   r.payload,
+		Recurring: true,
+	}
+}
+
+// ScheduleRecurring registers a recurring definition and queues its next occurrence.
+// Re-scheduling an existing definition with changed parameters replaces it.
+func (s *Scheduler) ScheduleRecurring(r *RecurringTask) error {
+	if err := IsValidRecurringTask(r); err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if existing, ok := s.recurring[r.id]; ok {
+		if existing.Equals(r) {
+			return ErrTaskAlreadyExists
+		}
+
+		log.Info("recurring task changed, replacing queued occurrences", zap.String("id", r.id))
+		s.removeRecurring(r.id, false)
+	}
+
+	// The first occurrence has to fit, otherwise the definition is rejected
+	start, ok := r.next(s.clock.Now())
+	if !ok {
+		return ErrRecurrenceEnded
+	}
+	if err := s.submit(r.occurrence(start)); err != nil {
+		return err
+	}
+
+	s.recurring[r.id] = r
+	s.persist()
+	return nil
+}
+
+// expandRecurring queues the next occurrence after the given time, conflicting occurrences are skipped
+// must be called with the lock held
+func (s *Scheduler) expandRecurring(r *RecurringTask, after time.Time) {
+	for i := 0; i < MaxRecurringSkips; i++ {
+		start, ok := r.next(after.Add(time.Nanosecond))
+		if !ok {
+			log.Info("recurring task has no further occurrences", zap.String("id", r.id))
+			delete(s.recurring, r.id)
+			s.persist()
+			return
+		}
+
+		err := s.schedule(r.occurrence(start))
+		if err == nil || errors.Is(err, ErrTaskAlreadyExists) {
+			return
+		}
+
+		log.Warn("skipping occurrence of recurring task", zap.String("id", OccurrenceID(r.id, start)), zap.Error(err))
+		after = start
+	}
+
+	log.Error("too many conflicting occurrences, recurring task stalled", zap.String("id", r.id))
+}
+
+// removeRecurring drops a definition and its queued occurrences, must be called with the lock held
+func (s *Scheduler) removeRecurring(id string, cancelRunning bool) bool {
+	if _, ok := s.recurring[id]; !ok {
+		return false
+	}
+	delete(s.recurring, id)
+
+	s.removeQueuedTasks(func(t *Task) bool {
+		return t.parent != nil && t.parent.id == id
+	})
+
+	if cancelRunning {
+		for _, t := range s.running {
+			if t.parent != nil && t.parent.id == id {
+				t.cancel(ErrTaskCancelled)
+			}
+		}
+	}
+
+	s.persist()
+	return true
+}
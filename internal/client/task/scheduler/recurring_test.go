@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecurringValidation(t *testing.T) {
+	noop := func(_ context.Context, _ interface{}) error { return nil }
+	now := time.Now()
+
+	// Neither interval nor cron
+	assert.ErrorIs(t, IsValidRecurringTask(NewRecurringTask(now, time.Second, noop, nil).WithID("a")), ErrRecurrenceInvalid)
+
+	// Occurrences longer than the interval
+	assert.ErrorIs(t, IsValidRecurringTask(NewRecurringTask(now, time.Minute, noop, nil).WithID("a").WithInterval(time.Minute)), ErrRecurrenceOverlap)
+
+	// Occurrences longer than the shortest gap of the cron schedule
+	cron, err := ParseCron("0,10 3 * * *")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, IsValidRecurringTask(NewRecurringTask(now, 10*time.Minute, noop, nil).WithID("a").WithCron(cron)), ErrRecurrenceOverlap)
+	assert.NoError(t, IsValidRecurringTask(NewRecurringTask(now, 9*time.Minute, noop, nil).WithID("a").WithCron(cron)))
+
+	// Weekdays only, the shortest gap is a day
+	cron, err = ParseCron("0 3 * * 1-5")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, IsValidRecurringTask(NewRecurringTask(now, 24*time.Hour, noop, nil).WithID("a").WithCron(cron)), ErrRecurrenceOverlap)
+	assert.NoError(t, IsValidRecurringTask(NewRecurringTask(now, 2*time.Hour, noop, nil).WithID("a").WithCron(cron)))
+
+	// End date before the start
+	assert.ErrorIs(t, IsValidRecurringTask(NewRecurringTask(now, time.Second, noop, nil).WithID("a").WithInterval(time.Minute).WithUntil(now.Add(-time.Hour))), ErrTaskTimesInvalid)
+
+	assert.NoError(t, IsValidRecurringTask(NewRecurringTask(now, time.Second, noop, nil).WithID("a").WithInterval(time.Minute)))
+}
+
+func TestRecurringOccurrences(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(2)
+	go s.Run()
+	defer s.Shutdown()
+
+	ch := make(chan time.Time, 10)
+	start := time.Now().Add(100 * time.Millisecond)
+	task := NewRecurringTask(start, 50*time.Millisecond, func(_ context.Context, _ interface{}) error {
+		ch <- time.Now()
+		return nil
+	}, nil).
+		WithID("recurring").
+		WithInterval(200 * time.Millisecond).
+		WithUntil(start.Add(450 * time.Millisecond)).
+		WithResource(SDRDevice1)
+
+	assert.NoError(t, s.ScheduleRecurring(task))
+	assert.ErrorIs(t, s.ScheduleRecurring(task), ErrTaskAlreadyExists)
+
+	// The queued occurrence lives in its own id namespace
+	s.m.RLock()
+	assert.Len(t, s.queue, 1)
+	assert.Equal(t, OccurrenceID("recurring", start), s.queue[0].ID())
+	s.m.RUnlock()
+
+	// Occurrences claim the resources like every other task
+	conflicting := NewTask(start, start.Add(10*time.Millisecond), func(_ context.Context, _ interface{}) error { return nil }, nil).WithResource(SDRDevice1)
+	assert.ErrorIs(t, s.Schedule(conflicting), ErrResourceSharingNotPossible)
+
+	// Three occurrences fit before the end date
+	for i := 0; i < 3; i++ {
+		select {
+		case at := <-ch:
+			assert.False(t, at.Before(start.Add(time.Duration(i)*200*time.Millisecond)))
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for occurrence %d", i)
+		}
+	}
+
+	select {
+	case <-ch:
+		t.Error("occurrence after the end date")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	s.m.RLock()
+	assert.Empty(t, s.recurring)
+	s.m.RUnlock()
+}
+
+func TestRecurringCancel(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(1)
+
+	noop := func(_ context.Context, _ interface{}) error { return nil }
+	start := time.Now().Add(time.Hour)
+	task := NewRecurringTask(start, time.Minute, noop, nil).WithID("recurring").WithInterval(time.Hour)
+	assert.NoError(t, s.ScheduleRecurring(task))
+
+	// Cancelling a single occurrence queues the next one
+	assert.True(t, s.Cancel(OccurrenceID("recurring", start)))
+	assert.Len(t, s.queue, 1)
+	assert.Equal(t, OccurrenceID("recurring", start.Add(time.Hour)), s.queue[0].ID())
+
+	// Cancelling the definition removes everything
+	assert.True(t, s.Cancel("recurring"))
+	assert.Empty(t, s.queue)
+	assert.Empty(t, s.recurring)
+}
@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"go.uber.org/zap"
+)
+
+// Resource is a named resource with a capacity, e.g. the number of attached SDRs
+type Resource string
+
+const (
+	// Number of SDRs
+	SDRDevice Resource = "SDRDevice"
+	// CPU budget in percent of the whole system
+	CPUShare Resource = "CPUShare"
+	// Disk write bandwidth budget in KiB/s
+	DiskWriteBandwidth Resource = "DiskWriteBandwidth"
+	// Add other resources here, to DefaultCapacities and to config.ResourceNames
+)
+
+// ResourceMode defines how claims of the same resource add up
+type ResourceMode int
+
+const (
+	// ExclusiveWrite claims use their units alone, concurrent claims add up
+	ExclusiveWrite ResourceMode = iota
+	// SharedRead claims can use the same units concurrently, only the biggest one counts
+	SharedRead
+)
+
+func (m ResourceMode) String() string {
+	if m == SharedRead {
+		return "shared-read"
+	}
+
+	return "exclusive-write"
+}
+
+// ResourceClaim is the demand of a task for a single resource
+type ResourceClaim struct {
+	Resource Resource     `json:"resource"`
+	Amount   int          `json:"amount"`
+	Mode     ResourceMode `json:"mode"`
+}
+
+type ResourceClaims []ResourceClaim
+
+// Capacities maps resources to the amount of available units
+type Capacities map[Resource]int
+
+var (
+	// DefaultCapacities is used if the scheduler was not configured otherwise
+	// Resources without a capacity have exactly one unit
+	DefaultCapacities = Capacities{
+		SDRDevice:          1,
+		CPUShare:           100,
+		DiskWriteBandwidth: 10 * 1024,
+	}
+
+	// Commonly used claims
+	SDRDevice1 = Exclusive(SDRDevice, 1)
+	FullCPU    = Exclusive(CPUShare, 100)
+)
+
+func Exclusive(resource Resource, amount int) ResourceClaim {
+	return ResourceClaim{Resource: resource, Amount: amount, Mode: ExclusiveWrite}
+}
+
+func Shared(resource Resource, amount int) ResourceClaim {
+	return ResourceClaim{Resource: resource, Amount: amount, Mode: SharedRead}
+}
+
+// sorted returns the claims in a stable order
+func (c ResourceClaims) sorted() ResourceClaims {
+	claims := append(ResourceClaims{}, c...)
+	sort.Slice(claims, func(i, j int) bool {
+		if claims[i].Resource != claims[j].Resource {
+			return claims[i].Resource < claims[j].Resource
+		}
+		return claims[i].Mode < claims[j].Mode
+	})
+
+	return claims
+}
+
+// capacity returns the amount of units for a resource
+func (c Capacities) capacity(resource Resource) int {
+	if n, ok := c[resource]; ok {
+		return n
+	}
+
+	return 1
+}
+
+// demand calculates the concurrent demand for a resource of a set of tasks
+func demand(resource Resource, tasks []*Task) int {
+	exclusive, shared := 0, 0
+	for _, t := range tasks {
+		for _, c := range t.resources {
+			if c.Resource != resource {
+				continue
+			}
+
+			if c.Mode == SharedRead {
+				shared = max(shared, c.Amount)
+			} else {
+				exclusive += c.Amount
+			}
+		}
+	}
+
+	return exclusive + shared
+}
+
+// Overlaps checks if the execution windows of both tasks overlap, touching windows count as overlap
+func (t *Task) Overlaps(other *Task) bool {
+	return !t.StartTime.After(other.EndTime) && !t.EndTime.Before(other.StartTime)
+}
+
+// fits checks if the resources claimed by newTask are available during its entire window
+// when running concurrently with the given tasks
+func (c Capacities) fits(newTask *Task, others []*Task) bool {
+	if len(newTask.resources) == 0 {
+		return true
+	}
+
+	overlapping := make([]*Task, 0, len(others))
+	for _, t := range others {
+		if newTask.Overlaps(t) && len(t.resources) != 0 {
+			overlapping = append(overlapping, t)
+		}
+	}
+
+	// The demand only rises when a task starts, so checking the window start
+	// and every start within the window covers the peak demand
+	points := []time.Time{newTask.StartTime}
+	for _, t := range overlapping {
+		if t.StartTime.After(newTask.StartTime) {
+			points = append(points, t.StartTime)
+		}
+	}
+
+	for _, p := range points {
+		active := []*Task{newTask}
+		for _, t := range overlapping {
+			if !t.StartTime.After(p) && !t.EndTime.Before(p) {
+				active = append(active, t)
+			}
+		}
+
+		for _, claim := range newTask.resources {
+			if d := demand(claim.Resource, active); d > c.capacity(claim.Resource) {
+				log.Debug("resource demand exceeds capacity",
+					zap.String("resource", string(claim.Resource)),
+					zap.Int("demand", d),
+					zap.Int("capacity", c.capacity(claim.Resource)),
+					zap.Time("at", p))
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/internal/client/config"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerCapacities(t *testing.T) {
+	log.Init(true)
+	noop := func(_ context.Context, _ interface{}) error { return nil }
+	start := time.Now().Add(time.Hour)
+	window := func(offset time.Duration) (time.Time, time.Time) {
+		return start.Add(offset), start.Add(offset + time.Hour)
+	}
+
+	s := NewScheduler(2)
+
+	// A spectrum survey only reads the SDR and a status upload uses some CPU, both can coexist
+	from, to := window(0)
+	survey := NewTask(from, to, noop, nil).WithResource(Shared(SDRDevice, 1), Exclusive(CPUShare, 40))
+	upload := NewTask(from, to, noop, nil).WithResource(Exclusive(CPUShare, 20), Exclusive(DiskWriteBandwidth, 512))
+	assert.NoError(t, s.Schedule(survey))
+	assert.NoError(t, s.Schedule(upload))
+
+	// A second reader of the same SDR is fine as well
+	assert.NoError(t, s.Schedule(NewTask(from, to, noop, nil).WithResource(Shared(SDRDevice, 1))))
+
+	// A capture needs the SDR exclusively
+	assert.ErrorIs(t, s.Schedule(NewTask(from, to, noop, nil).WithResource(SDRDevice1)), ErrResourceSharingNotPossible)
+
+	// The CPU budget is exhausted at 100%
+	assert.ErrorIs(t, s.Schedule(NewTask(from, to, noop, nil).WithResource(Exclusive(CPUShare, 41))), ErrResourceSharingNotPossible)
+	assert.NoError(t, s.Schedule(NewTask(from, to, noop, nil).WithResource(Exclusive(CPUShare, 40))))
+
+	// Claims bigger than the capacity never fit
+	from, to = window(10 * time.Hour)
+	assert.ErrorIs(t, s.Schedule(NewTask(from, to, noop, nil).WithResource(Exclusive(SDRDevice, 2))), ErrResourceSharingNotPossible)
+
+	// With two SDRs two captures fit, the third one conflicts
+	s.WithCapacity(SDRDevice, 2)
+	assert.NoError(t, s.Schedule(NewTask(from, to, noop, nil).WithResource(SDRDevice1)))
+	assert.NoError(t, s.Schedule(NewTask(from, to, noop, nil).WithResource(SDRDevice1)))
+	assert.ErrorIs(t, s.Schedule(NewTask(from, to, noop, nil).WithResource(SDRDevice1)), ErrResourceSharingNotPossible)
+}
+
+func TestCapacityPeakDemand(t *testing.T) {
+	start := time.Now()
+	task := func(from, to int) *Task {
+		return NewTask(start.Add(time.Duration(from)*time.Minute), start.Add(time.Duration(to)*time.Minute), nil, nil).WithResource(SDRDevice1)
+	}
+
+	capacities := Capacities{SDRDevice: 2}
+
+	// Two tasks that overlap the new one but not each other only need one additional unit
+	assert.True(t, capacities.fits(task(0, 10), []*Task{task(0, 4), task(6, 10)}))
+
+	// Overlapping each other they need all units at once
+	assert.False(t, capacities.fits(task(0, 10), []*Task{task(2, 6), task(4, 8)}))
+}
+
+func TestResourceNames(t *testing.T) {
+	// The config checks the configured capacities against the names of the resources
+	names := make([]string, 0, len(DefaultCapacities))
+	for resource := range DefaultCapacities {
+		names = append(names, string(resource))
+	}
+	assert.ElementsMatch(t, config.ResourceNames, names)
+}
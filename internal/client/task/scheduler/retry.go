@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"go.uber.org/zap"
+)
+
+// RetryPolicy defines how often a failed task is started again within its window
+type RetryPolicy struct {
+	// Maximum number of attempts including the first one
+	MaxAttempts int
+	// Delay before the first retry, it doubles with every further attempt
+	Backoff time.Duration
+	// Optional upper bound for the delay
+	MaxBackoff time.Duration
+	// Errors worth retrying, matched with errors.Is, every error is retried if empty
+	Retryable []error
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if len(p.Retryable) == 0 {
+		return true
+	}
+
+	for _, target := range p.Retryable {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns the delay after the given failed attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+
+	return delay
+}
+
+func (t *Task) WithRetry(policy RetryPolicy) *Task {
+	t.retry = &policy
+	return t
+}
+
+// Attempt returns the attempt of the running task starting at 1 and the maximum number of attempts
+func Attempt(ctx context.Context) (int, int) {
+	t, ok := ctx.Value(taskContextKey{}).(*Task)
+	if !ok {
+		return 0, 0
+	}
+
+	if t.retry == nil {
+		return t.attempt, 1
+	}
+
+	return t.attempt, max(t.retry.MaxAttempts, 1)
+}
+
+// Timing returns when the running task was first queued and when its current attempt started
+func Timing(ctx context.Context) (scheduled time.Time, started time.Time) {
+	t, ok := ctx.Value(taskContextKey{}).(*Task)
+	if !ok {
+		return time.Time{}, time.Time{}
+	}
+
+	return t.scheduledAt, t.startedAt
+}
+
+// WillRetry reports if the running task will be started again when it fails with err,
+// this allows the task to report the failed attempt accordingly
+func WillRetry(ctx context.Context, err error) bool {
+	t, ok := ctx.Value(taskContextKey{}).(*Task)
+	if !ok {
+		return false
+	}
+
+	_, ok = t.nextAttempt(ctx, err, t.clock.Now())
+	return ok
+}
+
+// nextAttempt returns the start of the next attempt if the failed run should be retried
+func (t *Task) nextAttempt(ctx context.Context, err error, now time.Time) (time.Time, bool) {
+	if err == nil || t.retry == nil || t.attempt >= t.retry.MaxAttempts {
+		return time.Time{}, false
+	}
+
+	// Cancelled, preempted or the window ended
+	if context.Cause(ctx) != nil {
+		return time.Time{}, false
+	}
+
+	if !t.retry.retryable(err) {
+		return time.Time{}, false
+	}
+
+	// The next attempt has to start within the window
+	start := now.Add(t.retry.backoff(t.attempt))
+	if !start.Before(t.EndTime) {
+		return time.Time{}, false
+	}
+
+	return start, true
+}
+
+// retryAt creates the next attempt of the task, the results of its dependencies are kept while it runs
+func (t *Task) retryAt(start time.Time) *Task {
+	r := NewTask(start, t.EndTime, t.Command, t.Argument).
+		WithID(t.id).
+		WithResource(t.resources...).
+		WithPriority(t.Priority).
+		WithPayload(t.payload)
+	r.PreExecute = t.PreExecute
+	r.PostExecute = t.PostExecute
+	r.parent = t.parent
+	r.retry = t.retry
+	r.timeCritical = t.timeCritical
+	r.attempt = t.attempt
+	r.dependencies = slices.Clone(t.dependencies)
+	r.inputs = t.inputs
+	r.scheduledAt = t.scheduledAt
+
+	return r
+}
+
+// keepRetry carries the attempt count and the backoff of a queued retry over to a task replacing it,
+// e.g. when the server sends the job again with its original start time
+func (t *Task) keepRetry(queued *Task) {
+	if queued.attempt == 0 {
+		return
+	}
+
+	t.attempt = queued.attempt
+	if t.StartTime.Before(queued.StartTime) && queued.StartTime.Before(t.EndTime) {
+		t.StartTime = queued.StartTime
+	}
+}
+
+// scheduleRetry queues the next attempt of a failed task, returns false if there is none
+// must be called with the lock held
+func (s *Scheduler) scheduleRetry(retry *Task) bool {
+	if retry == nil {
+		return false
+	}
+
+	if err := s.schedule(retry); err != nil {
+		log.Warn("could not queue the next attempt of the task", zap.String("id", retry.id), zap.Error(err))
+		return false
+	}
+
+	log.Info("queued the next attempt of the task",
+		zap.String("id", retry.id),
+		zap.Int("attempt", retry.attempt+1),
+		zap.Int("maxAttempts", retry.retry.MaxAttempts),
+		zap.Time("start", retry.StartTime))
+	return true
+}
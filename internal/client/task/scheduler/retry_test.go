@@ -0,0 +1,193 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var errBusy = errors.New("device busy")
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, 5*time.Second, p.backoff(4))
+	assert.Equal(t, 5*time.Second, p.backoff(40))
+
+	assert.True(t, (&RetryPolicy{}).retryable(errBusy))
+	assert.True(t, (&RetryPolicy{Retryable: []error{errBusy}}).retryable(errors.Join(errors.New("capture"), errBusy)))
+	assert.False(t, (&RetryPolicy{Retryable: []error{errBusy}}).retryable(errors.New("bad arguments")))
+}
+
+func TestRetryAttempts(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(1)
+	go s.Run()
+	defer s.Shutdown()
+
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond, Retryable: []error{errBusy}}
+	attempts := make(chan int, 3)
+	reported := make(chan bool, 3)
+	var firstScheduled time.Time
+
+	// Fails twice before the SDR is free
+	task := NewTask(time.Now(), time.Now().Add(time.Minute), func(ctx context.Context, _ interface{}) error {
+		attempt, maxAttempts := Attempt(ctx)
+		assert.Equal(t, 3, maxAttempts)
+
+		// Every attempt keeps the time the task was first queued
+		scheduled, started := Timing(ctx)
+		assert.False(t, scheduled.IsZero())
+		assert.False(t, started.Before(scheduled))
+		if attempt == 1 {
+			firstScheduled = scheduled
+		} else {
+			assert.Equal(t, firstScheduled, scheduled)
+		}
+		attempts <- attempt
+
+		if attempt < 3 {
+			reported <- WillRetry(ctx, errBusy)
+			return errBusy
+		}
+		return nil
+	}, nil).WithID("capture").WithRetry(policy)
+	assert.NoError(t, s.Schedule(task))
+
+	for i := 1; i <= 3; i++ {
+		select {
+		case attempt := <-attempts:
+			assert.Equal(t, i, attempt)
+		case <-time.After(time.Second):
+			t.Fatalf("attempt %d did not start", i)
+		}
+	}
+	assert.True(t, <-reported)
+	assert.True(t, <-reported)
+
+	// Errors that are not retryable end the task right away
+	failed := make(chan error, 1)
+	task = NewTask(time.Now(), time.Now().Add(time.Minute), func(ctx context.Context, _ interface{}) error {
+		return errors.New("bad arguments")
+	}, nil).WithID("invalid").WithRetry(policy)
+	task.PostExecute = func(err error) { failed <- err }
+	assert.NoError(t, s.Schedule(task))
+
+	<-failed
+	assert.Eventually(t, func() bool { return !s.HasRunningJob() }, time.Second, 10*time.Millisecond)
+	s.m.RLock()
+	assert.Empty(t, s.queue)
+	s.m.RUnlock()
+}
+
+func TestRetryWindow(t *testing.T) {
+	now := time.Now()
+	task := NewTask(now, now.Add(time.Minute), nil, nil).WithRetry(RetryPolicy{MaxAttempts: 5, Backoff: 40 * time.Second})
+	task.attempt = 1
+	ctx := context.Background()
+
+	// The first retry fits into the window, the second one would start after it ended
+	start, ok := task.nextAttempt(ctx, errBusy, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(40*time.Second), start)
+
+	task.attempt = 2
+	_, ok = task.nextAttempt(ctx, errBusy, now)
+	assert.False(t, ok)
+
+	// Cancelled tasks are never retried
+	task.attempt = 1
+	cancelled, cancel := context.WithCancelCause(ctx)
+	cancel(ErrTaskPreempted)
+	_, ok = task.nextAttempt(cancelled, errBusy, now)
+	assert.False(t, ok)
+}
+
+func TestRetryReplaced(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(1)
+	go s.Run()
+	defer s.Shutdown()
+
+	now := time.Now()
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Minute, Retryable: []error{errBusy}}
+	newCapture := func(end time.Time) *Task {
+		return NewTask(now, end, func(ctx context.Context, _ interface{}) error {
+			return errBusy
+		}, nil).WithID("capture").WithRetry(policy)
+	}
+	assert.NoError(t, s.Schedule(newCapture(now.Add(time.Hour))))
+
+	queued := func() *Task {
+		s.m.RLock()
+		defer s.m.RUnlock()
+
+		if len(s.queue) != 1 {
+			return nil
+		}
+		return s.queue[0]
+	}
+	assert.Eventually(t, func() bool { q := queued(); return q != nil && q.attempt == 1 }, time.Second, 10*time.Millisecond)
+	backoffStart := queued().StartTime
+	assert.True(t, backoffStart.After(now))
+
+	// The server sends the job again, the queued retry is kept as it is
+	assert.ErrorIs(t, s.Schedule(newCapture(now.Add(time.Hour))), ErrTaskAlreadyExists)
+
+	// A modified window keeps the attempt count and the backoff
+	assert.NoError(t, s.Schedule(newCapture(now.Add(2*time.Hour))))
+	if q := queued(); assert.NotNil(t, q) {
+		assert.Equal(t, 1, q.attempt)
+		assert.Equal(t, backoffStart, q.StartTime)
+		assert.Equal(t, now.Add(2*time.Hour), q.EndTime)
+	}
+}
+
+func TestRetryDependencies(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(1)
+	go s.Run()
+	defer s.Shutdown()
+
+	now := time.Now()
+	prepare := NewTask(now, now.Add(time.Minute), func(ctx context.Context, _ interface{}) error {
+		SetOutput(ctx, "config")
+		return nil
+	}, nil).WithID("prepare")
+
+	// Every attempt keeps the dependency and gets its output
+	inputs := make(chan interface{}, 2)
+	capture := NewTask(now, now.Add(time.Minute), func(ctx context.Context, _ interface{}) error {
+		attempt, _ := Attempt(ctx)
+		assert.Equal(t, []Dependency{{ID: "prepare", Condition: OnSuccess}}, ctx.Value(taskContextKey{}).(*Task).Dependencies())
+		inputs <- Inputs(ctx)["prepare"]
+		if attempt == 1 {
+			return errBusy
+		}
+		return nil
+	}, nil).WithID("capture").WithDependency("prepare", OnSuccess).WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: 10 * time.Millisecond})
+	assert.NoError(t, s.ScheduleGroup(prepare, capture))
+
+	for i := 1; i <= 2; i++ {
+		select {
+		case input := <-inputs:
+			assert.Equal(t, "config", input)
+		case <-time.After(time.Second):
+			t.Fatalf("attempt %d did not start", i)
+		}
+	}
+
+	// The results are dropped once the retry ended
+	assert.Eventually(t, func() bool {
+		s.m.RLock()
+		defer s.m.RUnlock()
+
+		return len(s.running) == 0 && len(s.results) == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -3,29 +3,21 @@ package scheduler
 import (
 	"container/heap"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/LeoCommon/client/pkg/clock"
 	"github.com/LeoCommon/client/pkg/log"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
-type ExclusiveResource string
-type ExclusiveResources []ExclusiveResource
-
-type ExclusiveResourceMap map[ExclusiveResource]bool
-
 const (
-	SDRDevice1 ExclusiveResource = "SDRDevice1"
-	FullCPU    ExclusiveResource = "FullCPU"
-	// Add other exclusive resources here
-
-	// The amount of time the scheduler sleeps between ticks
-	// This allows 10 "concurrent" events per second with 1 worker, this is okay for now
-	TickRate = 100 * time.Millisecond
-
 	// Maximum job duration
 	MaxTaskDuration = 24 * time.Hour
 )
@@ -35,9 +27,13 @@ type JobFunction func(context.Context, interface{}) error
 var (
 	ErrTaskAlreadyRunning         = errors.New("an identical task is being executed already")
 	ErrTaskAlreadyExists          = errors.New("an identical task already existed")
-	ErrResourceSharingNotPossible = errors.New("cant share priority resources with overlapping task")
+	ErrResourceSharingNotPossible = errors.New("not enough resource capacity left during the task window")
 	ErrRunningTaskCantBeModified  = errors.New("an already running task can not be modified")
 	ErrTaskNotFound               = errors.New("task not found")
+	ErrTaskExpired                = errors.New("task window passed before it could start")
+	ErrTaskPreempted              = errors.New("task was preempted by a task with a higher priority")
+	// Still a context.Canceled, so jobs that only check for it keep working
+	ErrTaskCancelled = fmt.Errorf("task was cancelled: %w", context.Canceled)
 
 	// Task validation errors
 	ErrTaskIDInvalid           = errors.New("invalid/empty task id")
@@ -47,22 +43,54 @@ var (
 )
 
 type Task struct {
-	StartTime          time.Time
-	EndTime            time.Time
-	Argument           interface{}
-	Command            JobFunction
-	cancelFunc         context.CancelFunc
-	cancelOnce         sync.Once
-	PreExecute         func() bool
-	PostExecute        func(error)
-	exclusiveResources ExclusiveResourceMap
-	id                 string // An unique ID
+	StartTime   time.Time
+	EndTime     time.Time
+	Argument    interface{}
+	Command     JobFunction
+	cancelFunc  context.CancelCauseFunc
+	cancelOnce  sync.Once
+	PreExecute  func() bool
+	PostExecute func(error)
+	// Tasks with a higher priority can preempt overlapping tasks with a lower one
+	Priority  int
+	resources ResourceClaims
+	id        string // An unique ID
+	// Opaque data that allows rebuilding the task from the journal
+	payload json.RawMessage
+	// The recurring definition this task is an occurrence of
+	parent *RecurringTask
+	// Set once the task was preempted, it no longer counts towards the resource demand
+	preempted bool
+	// Optional retry policy and the number of started attempts
+	retry   *RetryPolicy
+	attempt int
+	// When the task was first queued and when the current attempt started, the clock of the scheduler running it
+	scheduledAt time.Time
+	startedAt   time.Time
+	clock       clock.Clock
+	// Cancels the running task at its EndTime
+	deadline *clock.Deadline
+	// Only started once the time is synchronized
+	timeCritical bool
+	// Tasks that have to end before this one starts
+	dependencies []Dependency
+	// Outputs of the dependencies and the own output, see Inputs and SetOutput
+	inputs map[string]interface{}
+	output interface{}
 }
 
 // Cancel cancels a task (only once)
 func (t *Task) Cancel() {
+	t.cancel(context.Canceled)
+}
+
+// cancel cancels the task context with the given cause (only once)
+func (t *Task) cancel(cause error) {
 	t.cancelOnce.Do(func() {
-		t.cancelFunc()
+		// Queued tasks have no context yet
+		if t.cancelFunc != nil {
+			t.cancelFunc(cause)
+		}
 	})
 }
 
@@ -76,45 +104,37 @@ func (t *Task) Equals(other *Task) bool {
 	}
 	return t.StartTime.Equal(other.StartTime) &&
 		t.EndTime.Equal(other.EndTime) &&
+		t.Priority == other.Priority &&
+		slices.Equal(t.dependencies, other.dependencies) &&
 		t.id == other.id
 }
 
-func (t *Task) HasResourceOverlap(other *Task) bool {
-	// Check if an overlapping task uses the same resources as another task
-	if (t.StartTime.Before(other.EndTime) || t.StartTime.Equal(other.EndTime)) &&
-		(t.EndTime.After(other.StartTime) || t.EndTime.Equal(other.StartTime)) {
-
-		// Check if any resource is also used by the overlapping task
-		for k := range other.exclusiveResources {
-			if _, ok := t.exclusiveResources[k]; ok {
-				log.Debug("task cant share this exclusive resource", zap.String("resource", string(k)))
-				return true
-			}
-		}
-	}
-
-	return false
-}
-
 func NewTask(startTime time.Time, endTime time.Time, command func(context.Context, interface{}) error, arg interface{}) *Task {
 	return &Task{
-		id:                 uuid.NewString(),
-		StartTime:          startTime,
-		EndTime:            endTime,
-		Command:            command,
-		Argument:           arg,
-		cancelOnce:         sync.Once{},
-		exclusiveResources: make(ExclusiveResourceMap),
+		id:         uuid.NewString(),
+		StartTime:  startTime,
+		EndTime:    endTime,
+		Command:    command,
+		Argument:   arg,
+		cancelOnce: sync.Once{},
 	}
 }
 
-func (t *Task) WithResource(resources ...ExclusiveResource) *Task {
-	for _, resource := range resources {
-		t.exclusiveResources[resource] = true
-	}
+func (t *Task) WithResource(claims ...ResourceClaim) *Task {
+	t.resources = append(t.resources, claims...)
 	return t
 }
 
+func (t *Task) WithPriority(priority int) *Task {
+	t.Priority = priority
+	return t
+}
+
+// Resources returns the resource claims of the task
+func (t *Task) Resources() ResourceClaims {
+	return t.resources
+}
+
 func (t *Task) WithID(id string) *Task {
 	if len(id) == 0 {
 		log.Panic("empty task id in scheduler will break it, panic")
@@ -124,11 +144,26 @@ func (t *Task) WithID(id string) *Task {
 	return t
 }
 
+// WithPayload attaches the data that is journaled for this task, tasks without payload are not persisted
+func (t *Task) WithPayload(payload json.RawMessage) *Task {
+	t.payload = payload
+	return t
+}
+
+// ID returns the unique task id
+func (t *Task) ID() string {
+	return t.id
+}
+
 type taskQueue []*Task
 
 func (q taskQueue) Len() int { return len(q) }
 
 func (q taskQueue) Less(i, j int) bool {
+	// Tasks starting at the same time are started by priority
+	if q[i].StartTime.Equal(q[j].StartTime) {
+		return q[i].Priority > q[j].Priority
+	}
 	return q[i].StartTime.Before(q[j].StartTime)
 }
 
@@ -154,10 +189,34 @@ type Scheduler struct {
 	wg      sync.WaitGroup
 	quit    chan struct{}
 	workers chan struct{}
+	// Wakes the run loop so it can re-arm the timer
+	wake chan struct{}
 	// Queued tasks
 	queue taskQueue
 	// Running tasks
 	running []*Task
+	// Optional journal that keeps the tasks across restarts
+	journal Journal
+	// Recurring task definitions by id
+	recurring map[string]*RecurringTask
+	// Available units per resource
+	capacities Capacities
+	// Results of ended tasks that queued tasks depend on
+	results map[string]Result
+	// Recently ended tasks
+	history []TaskInfo
+	// Source of the current time and the timers
+	clock clock.Clock
+	// Optional file the panics of tasks are written to
+	crashLog string
+	// Set by Drain, no tasks are accepted or started anymore
+	draining      bool
+	drainDeadline time.Time
+	// Time critical tasks are held back while the wall clock can not be trusted
+	timeSynced bool
+	// Last reading of the clocks to detect steps of the wall clock
+	lastWall time.Time
+	lastMono time.Duration
 }
 
 func NewScheduler(numWorkers int) *Scheduler {
@@ -165,7 +224,111 @@ func NewScheduler(numWorkers int) *Scheduler {
 		queue:   make(taskQueue, 0),
 		workers: make(chan struct{}, numWorkers),
 		quit:    make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+
+		recurring:  make(map[string]*RecurringTask),
+		capacities: maps.Clone(DefaultCapacities),
+		results:    make(map[string]Result),
+		clock:      clock.Real,
+		timeSynced: true,
+	}
+}
+
+// WithClock replaces the wall clock, e.g. with a fake one in tests. Must be called before Run
+func (s *Scheduler) WithClock(c clock.Clock) *Scheduler {
+	s.clock = c
+	return s
+}
+
+// WithCapacity sets the amount of available units for a resource
+func (s *Scheduler) WithCapacity(resource Resource, units int) *Scheduler {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.capacities[resource] = units
+	return s
+}
+
+// notify asks the run loop to re-evaluate the queue, never blocks
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+		// A wake-up is already pending
+	}
+}
+
+// WithJournal enables persisting the queued and running tasks
+func (s *Scheduler) WithJournal(journal Journal) *Scheduler {
+	s.journal = journal
+	return s
+}
+
+// persist writes the current state to the journal, must be called with the lock held
+func (s *Scheduler) persist() {
+	if s.journal == nil {
+		return
+	}
+
+	records := make([]TaskRecord, 0, len(s.queue)+len(s.running)+len(s.recurring))
+	for _, r := range s.recurring {
+		if r.payload != nil {
+			records = append(records, r.record())
+		}
+	}
+	for _, t := range s.running {
+		if t.payload != nil {
+			records = append(records, t.record(true))
+		}
+	}
+	for _, t := range s.queue {
+		if t.payload != nil {
+			records = append(records, t.record(false))
+		}
+	}
+
+	if err := s.journal.Store(records); err != nil {
+		log.Error("could not write scheduler journal", zap.Error(err))
+	}
+}
+
+// Restore loads the journal and hands all queued tasks and recurring definitions to the rebuild function,
+// which is responsible for scheduling them again. Occurrences are expanded from their definition instead.
+// Tasks that were running when the journal was written did not complete, they are returned as interrupted.
+func (s *Scheduler) Restore(rebuild func(TaskRecord) error) ([]TaskRecord, error) {
+	if s.journal == nil {
+		return nil, nil
+	}
+
+	records, err := s.journal.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	interrupted := make([]TaskRecord, 0)
+	for _, record := range sortByDependencies(records) {
+		if record.Running {
+			interrupted = append(interrupted, record)
+			continue
+		}
+
+		if len(record.Parent) != 0 {
+			continue
+		}
+
+		if err := rebuild(record); err != nil {
+			log.Error("could not restore journaled task", zap.String("id", record.ID), zap.Error(err))
+			continue
+		}
 	}
+
+	// Drop the interrupted tasks from the journal
+	s.m.Lock()
+	s.persist()
+	s.m.Unlock()
+
+	log.Info("restored scheduler journal", zap.Int("records", len(records)), zap.Int("interrupted", len(interrupted)))
+	return interrupted, nil
 }
 
 func IsValidTask(task *Task) error {
@@ -202,6 +365,10 @@ func (s *Scheduler) Update(newTask *Task) error {
 	s.m.Lock()
 	defer s.m.Unlock()
 
+	if s.draining {
+		return ErrSchedulerDraining
+	}
+
 	// Only touch queued tasks
 	for i, task := range s.queue {
 		if task.id == newTask.id {
@@ -214,23 +381,22 @@ func (s *Scheduler) Update(newTask *Task) error {
 
 // modifyTaskAtIndex modifies a given task at index idx
 func (s *Scheduler) modifyTaskAtIndex(idx int, newTask *Task) error {
-	// We have to go through the entire queue again to make sure we dont resource share with the new times
-	if s.matchQueueEntry(func(t *Task) bool {
-		// Skip the "old" entry
-		if t.id == newTask.id {
-			return false
-		}
-
-		return newTask.HasResourceOverlap(t)
-	}) {
+	// We have to check the demand again to make sure the resources suffice with the new times
+	victims, ok := s.makeRoom(newTask)
+	if !ok {
 		// We found an overlap, so we cant modify the task and we should not keep the old one
 		s.removeTaskFromQueue(idx)
+		s.complete(newTask, Result{Err: ErrResourceSharingNotPossible})
 		log.Warn("resource overlap, modification impossible, discarded orphaned task")
 		return ErrResourceSharingNotPossible
 	}
 
 	// Everything fine, its safe to adjust the queued task
+	newTask.scheduledAt = s.queue[idx].scheduledAt
 	s.heapFixInternal(idx, newTask)
+	s.preempt(victims, newTask)
+	s.persist()
+	s.notify()
 	log.Info("Modified existing scheduled task")
 	return nil
 }
@@ -240,15 +406,27 @@ func (s *Scheduler) heapFixInternal(idx int, newTask *Task) {
 	heap.Fix(&s.queue, idx)
 }
 
-// matchQueueEntry runs a match function on the queue and returns the match result
-func (s *Scheduler) matchQueueEntry(matcher func(*Task) bool) bool {
-	for i := 0; i < len(s.queue); i++ {
-		if matcher(s.queue[i]) {
-			return true
+// others returns all running and queued tasks except the one with the given id
+// Preempted tasks that did not stop yet are skipped, they are about to release their resources
+func (s *Scheduler) others(id string) []*Task {
+	tasks := make([]*Task, 0, len(s.running)+len(s.queue))
+	for _, t := range s.running {
+		if t.id != id && !t.preempted {
+			tasks = append(tasks, t)
+		}
+	}
+	for _, t := range s.queue {
+		if t.id != id {
+			tasks = append(tasks, t)
 		}
 	}
 
-	return false
+	return tasks
+}
+
+// admissible checks if the resource demand of the task can be met during its entire window
+func (s *Scheduler) admissible(newTask *Task) bool {
+	return s.capacities.fits(newTask, s.others(newTask.id))
 }
 
 // Schedule schedules a task
@@ -261,10 +439,31 @@ func (s *Scheduler) Schedule(newTask *Task) error {
 	s.m.Lock()
 	defer s.m.Unlock()
 
+	return s.submit(newTask)
+}
+
+// submit schedules a task handed in by the user, rejected new tasks end up in the history
+// must be called with the lock held
+func (s *Scheduler) submit(t *Task) error {
+	if s.draining {
+		return ErrSchedulerDraining
+	}
+
+	existed := s.find(t.id) != nil
+
+	err := s.schedule(t)
+	if err != nil && !existed && !errors.Is(err, ErrTaskAlreadyExists) {
+		s.remember(t, err, StateRejected)
+	}
+
+	return err
+}
+
+// schedule is the internal implementation of Schedule, must be called with the lock held
+func (s *Scheduler) schedule(newTask *Task) error {
 	// Check running tasks
 	// 1) if we get a schedule call without changes for a running job we return a "harmless" ErrTaskAlreadyRunning error
 	// 2) else we return the ErrRunningTaskCantBeModified error
-	// 3) if there is a resource conflict with a running task
 	for _, t := range s.running {
 		// If the entire task matches this is condition 1)
 		if t.Equals(newTask) {
@@ -277,25 +476,26 @@ func (s *Scheduler) Schedule(newTask *Task) error {
 			log.Debug("no changes to running tasks allowed")
 			return ErrRunningTaskCantBeModified
 		}
-
-		// make sure there is no resource overlap with a currently running task
-		if newTask.HasResourceOverlap(t) {
-			log.Debug("resource overlap with running task")
-			return ErrResourceSharingNotPossible
-		}
 	}
 
 	// At this point we know that the task is either
 	// - completely new
-	// - a modification of a queued task that does not use overlapping resources with any currently running tasks
+	// - a modification of a queued task
+
+	// Dependencies have to be scheduled first, this also keeps the dependency graph acyclic
+	if err := s.checkDependencies(newTask); err != nil {
+		return err
+	}
 
 	// Now check the queued tasks for
 	// 1) completely identical tasks
 	// 2) tasks with matching ids
-	// 3) overlapping queued tasks using restricted resource
 	for i := 0; i < len(s.queue); i++ {
 		// Grab existing task from the queue
 		eTask := s.queue[i]
+		if eTask.id == newTask.id {
+			newTask.keepRetry(eTask)
+		}
 
 		// If the exact same task already exists, we dont need to do anything
 		if eTask.Equals(newTask) {
@@ -308,32 +508,57 @@ func (s *Scheduler) Schedule(newTask *Task) error {
 		if eTask.id == newTask.id {
 			return s.modifyTaskAtIndex(i, newTask)
 		}
+	}
 
-		// Check if the new task uses the same resources as another task
-		if result := newTask.HasResourceOverlap(eTask); result {
-			return ErrResourceSharingNotPossible
-		}
+	// Check the total demand of all running and queued tasks during the window against the capacities
+	// if it does not fit, tasks with a lower priority might have to make room
+	victims, ok := s.makeRoom(newTask)
+	if !ok {
+		return ErrResourceSharingNotPossible
 	}
 
 	// We added a completely new task
 	log.Debug("scheduled as completely new task")
+	if newTask.scheduledAt.IsZero() {
+		newTask.scheduledAt = s.clock.Now()
+	}
 	heap.Push(&s.queue, newTask)
+	s.preempt(victims, newTask)
+	s.persist()
+	s.notify()
 	return nil
 }
 
+// Cancel cancels a queued or running task, or a recurring definition with all its occurrences
 func (s *Scheduler) Cancel(id string) bool {
 	s.m.Lock()
 	defer s.m.Unlock()
 
+	if s.removeRecurring(id, true) {
+		return true
+	}
+
 	// Try to remove it from the scheduled list
 	for i := 0; i < len(s.queue); i++ {
 		if s.queue[i].id == id {
+			task := s.queue[i]
 			s.removeTaskFromQueue(i)
-			break
+			s.complete(task, Result{Err: ErrTaskCancelled})
+
+			// Skipping a single occurrence keeps the definition alive
+			if p := task.parent; p != nil && s.recurring[p.id] == p {
+				s.expandRecurring(p, task.StartTime)
+			}
+			return true
 		}
 	}
 
-	// Try in the running task list
+	// Try in the running task list, the task observes the cancellation through context.Cause
+	for _, t := range s.running {
+		if t.id == id {
+			t.cancel(ErrTaskCancelled)
+		}
+	}
 	return s.finishUpTask(id)
 }
 
@@ -341,6 +566,27 @@ func (s *Scheduler) removeTaskFromQueue(idx int) {
 	// Cancel the context
 	s.queue[idx].Cancel()
 	heap.Remove(&s.queue, idx)
+	s.persist()
+	s.notify()
+}
+
+// removeQueuedTasks cancels all queued tasks that match
+func (s *Scheduler) removeQueuedTasks(matcher func(*Task) bool) {
+	for {
+		idx := -1
+		for i, t := range s.queue {
+			if matcher(t) {
+				idx = i
+				break
+			}
+		}
+
+		if idx < 0 {
+			return
+		}
+		s.complete(s.queue[idx], Result{Err: context.Canceled})
+		s.removeTaskFromQueue(idx)
+	}
 }
 
 // finishUpTask is an internal function that handles task completion
@@ -357,75 +603,157 @@ func (s *Scheduler) finishUpTask(id string) bool {
 
 		// remove the task from the running list
 		s.running = append(s.running[:i], s.running[i+1:]...)
+		s.persist()
 		return true
 	}
 
 	return false
 }
 
+// Run executes the scheduler loop until Shutdown is called.
+// Instead of polling, a single timer is armed to the start time of the next queued task.
+// The loop also wakes up every ClockCheckInterval to detect steps of the wall clock.
 func (s *Scheduler) Run() {
-	// Create the main scheduler ticker
-	ticker := time.NewTicker(TickRate)
-	defer ticker.Stop()
+	s.lastWall, s.lastMono = s.clock.Now(), s.clock.Monotonic()
+	timer := s.clock.NewTimer(0)
+	defer timer.Stop()
 
-tickLoop:
 	for {
 		select {
-		case <-ticker.C:
-			s.tick()
-
+		case <-timer.C():
+		case <-s.wake:
 		case <-s.quit:
-			break tickLoop
+			log.Debug("scheduler run completed")
+			return
 		}
-	}
 
-	log.Debug("scheduler run completed")
+		// Start everything that is due and re-arm the timer for the next task,
+		// the timer measures monotonic time so the wall clock is checked regularly
+		s.checkClock()
+		if wait, ok := s.tick(); ok {
+			timer.Reset(min(wait, ClockCheckInterval))
+		} else {
+			timer.Reset(ClockCheckInterval)
+		}
+	}
 }
 
-// tick performs a single scheduler tick
-func (s *Scheduler) tick() {
-	// Block modificatiosn for the entire duration of the tick
+// tick starts all due tasks, it returns the time until the next task is due
+// and false if there is nothing to wait for (empty queue or no free workers)
+func (s *Scheduler) tick() (time.Duration, bool) {
+	// Block modifications for the entire duration of the tick
 	s.m.Lock()
 	defer s.m.Unlock()
 
+	// Due tasks whose resources are still in use by running tasks, a finishing worker will wake us up
+	held := make([]*Task, 0)
+	defer func() {
+		for _, t := range held {
+			heap.Push(&s.queue, t)
+		}
+
+		// Only now all waiting tasks are back in the queue
+		s.pruneResults()
+	}()
+
+	// Queued tasks stay in the journal while draining
+	if s.draining {
+		return 0, false
+	}
+
 	for len(s.queue) > 0 {
 		// Grab the very next task from the list
 		task := s.queue[0]
 
-		// First element not ready yet
-		if task.StartTime.After(time.Now().UTC()) {
-			break
+		// First element not ready yet, wait exactly until it is
+		if wait := task.StartTime.Sub(s.clock.Now()); wait > 0 {
+			return wait, true
 		}
 
-		// No workers available
+		// No workers available, a finishing worker will wake us up
 		if len(s.workers) == cap(s.workers) {
-			break
+			return 0, false
 		}
-		s.workers <- struct{}{}
 		heap.Pop(&s.queue)
 
-		// If the duration is bigger than 0 create the context with a timeout
-		var ctx context.Context
-		if taskDuration := task.EndTime.Sub(time.Now().UTC()); taskDuration > 0 {
-			ctx, task.cancelFunc = context.WithTimeout(context.Background(), taskDuration)
-		} else {
-			ctx, task.cancelFunc = context.WithCancel(context.Background())
+		// The window might have passed while the task was held back or the clock was stepped
+		if task.EndTime.Before(s.clock.Now()) {
+			log.Warn("task window passed before it could start, dropping task", zap.String("id", task.id))
+			s.drop(task, ErrTaskExpired)
+			continue
 		}
 
+		// Tasks whose dependencies failed will never run
+		ready, err := s.dependenciesMet(task)
+		if err != nil {
+			log.Warn("dependencies not met, dropping task", zap.String("id", task.id), zap.Error(err))
+			s.drop(task, err)
+			continue
+		}
+
+		// Admission was checked when scheduling, but running tasks might not have released their resources yet
+		// and time critical tasks have to wait for the time synchronization
+		if !ready || !s.capacities.fits(task, s.running) || (task.timeCritical && !s.timeSynced) {
+			log.Debug("dependencies, resources or time sync not available yet, holding back task", zap.String("id", task.id))
+			held = append(held, task)
+			continue
+		}
+		s.workers <- struct{}{}
+
+		// Queue the following occurrence of recurring tasks
+		if p := task.parent; p != nil && s.recurring[p.id] == p {
+			s.expandRecurring(p, task.StartTime)
+		}
+
+		// The cancel cause tells the task why it was stopped, e.g. ErrTaskPreempted
+		ctx, cancel := context.WithCancelCause(context.Background())
+		task.cancelFunc = cancel
+
+		// If the duration is bigger than 0 create the context with a deadline, it is re-armed if the clock is stepped
+		if task.EndTime.After(s.clock.Now()) {
+			var deadline *clock.Deadline
+			ctx, deadline = clock.WithDeadline(ctx, s.clock, task.EndTime)
+			task.deadline = deadline
+			task.cancelFunc = func(cause error) {
+				cancel(cause)
+				deadline.Stop()
+			}
+		}
+
+		// Hand over the outputs of the dependencies, retries keep the ones of the first attempt
+		if len(task.dependencies) != 0 {
+			task.inputs = s.inputsOf(task)
+		}
+		task.attempt++
+		task.startedAt = s.clock.Now()
+		task.clock = s.clock
+		ctx = context.WithValue(ctx, taskContextKey{}, task)
+
 		// Add the task to the running list
 		s.running = append(s.running, task)
+		s.persist()
 		s.wg.Add(1)
 
 		// Spawn the worker
 		go func(ctx context.Context) {
+			result := Result{Err: ErrTaskAborted}
+			var retry *Task
 			defer func() {
 				// Always finish up the task, regardless of how it ended
 				s.m.Lock()
 				s.finishUpTask(task.id)
+				if s.scheduleRetry(retry) {
+					s.remember(task, result.Err, StateRetrying)
+				} else {
+					s.complete(task, result)
+				}
 				s.m.Unlock()
 
 				<-s.workers
 				s.wg.Done()
+
+				// A worker is free again
+				s.notify()
 			}()
 
 			// If there is a pre-execute hook
@@ -436,8 +764,20 @@ func (s *Scheduler) tick() {
 				}
 			}
 
-			// Run the task
-			err := task.Command(ctx, task.Argument)
+			// Run the task, a panic only takes down this task
+			err := s.call(ctx, task)
+			result = Result{Err: err, Output: task.output}
+			// Only the history and the dependent tasks see the preemption, the task already reported it and the hook gets its own error
+			if errors.Is(context.Cause(ctx), ErrTaskPreempted) {
+				result.Err = ErrTaskPreempted
+			}
+
+			// Failed attempts might be retried within the window
+			if start, ok := task.nextAttempt(ctx, err, s.clock.Now()); ok {
+				retry = task.retryAt(start)
+			}
+
+			// Called after every attempt
 			if task.PostExecute != nil {
 				task.PostExecute(err)
 			} else if err != nil {
@@ -446,6 +786,17 @@ func (s *Scheduler) tick() {
 
 		}(ctx)
 	}
+
+	return 0, false
+}
+
+// drop removes a due task that will not run, must be called with the lock held
+func (s *Scheduler) drop(task *Task, err error) {
+	s.persist()
+	s.complete(task, Result{Err: err})
+	if task.PostExecute != nil {
+		go task.PostExecute(err)
+	}
 }
 
 // HasRunningJob returns true if at least one job is running, false otherwise
@@ -5,36 +5,50 @@ import (
 	"testing"
 	"time"
 
+	"github.com/LeoCommon/client/pkg/clock"
 	"github.com/LeoCommon/client/pkg/log"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
 
+// advanceUntil moves the fake clock forward step by step until cond holds,
+// the run loop gets a moment to re-arm its timer after every step
+func advanceUntil(t *testing.T, clk *clock.Fake, step time.Duration, cond func() bool) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		if cond() {
+			return true
+		}
+		clk.Advance(step)
+		return false
+	}, 2*time.Second, time.Millisecond)
+}
+
 func TestScheduler(t *testing.T) {
 	log.Init(true)
 
 	// Run with one worker, otherwise the order of the "same time" tasks is not deterministic
-	s := NewScheduler(1)
+	clk := clock.NewFake(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC))
+	s := NewScheduler(1).WithClock(clk)
 	go s.Run()
+	defer s.Shutdown()
 
 	// Create a channel to communicate the execution of the tasks
-	ch := make(chan string)
-
-	task1 := NewTask(time.Now().Add(time.Second*2), time.Now().Add(time.Second*4), func(_ context.Context, _ interface{}) error {
+	ch := make(chan string, 3)
+	now := clk.Now()
 
+	task1 := NewTask(now.Add(time.Second*2), now.Add(time.Second*4), func(_ context.Context, _ interface{}) error {
 		ch <- "task1 executed"
 		return nil
 	}, nil)
 
 	// Runs at the "same" time as task1 but doesnt share resources
-	task2 := NewTask(time.Now().Add(time.Second*2), time.Now().Add(time.Second*4), func(_ context.Context, _ interface{}) error {
-
+	task2 := NewTask(now.Add(time.Second*2), now.Add(time.Second*4), func(_ context.Context, _ interface{}) error {
 		ch <- "task2 executed"
 		return nil
 	}, nil)
 
-	task3 := NewTask(time.Now().Add(time.Second*4), time.Now().Add(time.Second*6), func(_ context.Context, _ interface{}) error {
-
+	task3 := NewTask(now.Add(time.Second*4), now.Add(time.Second*6), func(_ context.Context, _ interface{}) error {
 		ch <- "task3 executed"
 		return nil
 	}, nil)
@@ -43,36 +57,54 @@ func TestScheduler(t *testing.T) {
 	assert.NoError(t, s.Schedule(task2))
 	assert.NoError(t, s.Schedule(task3))
 
-	// Verify that task3 is executed at the correct time
-	select {
-	case msg := <-ch:
-		if msg != "task1 executed" {
-			t.Errorf("Expected task1 to execute, but got: %s", msg)
-		}
-	case <-time.After(time.Second * 5):
-		t.Error("Timeout waiting for task1 to execute")
-	}
+	// Nothing runs before its start time
+	advanceUntil(t, clk, time.Second, func() bool { return len(ch) == 2 })
+	assert.False(t, clk.Now().Before(task1.StartTime))
+	advanceUntil(t, clk, time.Second, func() bool { return len(ch) == 3 })
+	assert.False(t, clk.Now().Before(task3.StartTime))
 
-	select {
-	case msg := <-ch:
-		if msg != "task2 executed" {
-			t.Errorf("Expected task2 to execute, but got: %s", msg)
-		}
-	case <-time.After(time.Second * 1):
-		t.Error("Timeout waiting for task2 to execute")
-	}
+	// Verify the order of the executions
+	assert.Equal(t, "task1 executed", <-ch)
+	assert.Equal(t, "task2 executed", <-ch)
+	assert.Equal(t, "task3 executed", <-ch)
+}
 
-	// Verify that task2 is executed at the correct time
-	select {
-	case msg := <-ch:
-		if msg != "task3 executed" {
-			t.Errorf("Expected task3 to execute, but got: %s", msg)
-		}
-	case <-time.After(time.Second * 3):
-		t.Error("Timeout waiting for task3 to execute")
+func TestSchedulerMaxDuration(t *testing.T) {
+	log.Init(true)
+	clk := clock.NewFake(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC))
+	s := NewScheduler(2).WithClock(clk)
+	go s.Run()
+	defer s.Shutdown()
+
+	now := clk.Now()
+	waitForDeadline := func(ctx context.Context, _ interface{}) error {
+		<-ctx.Done()
+		return ctx.Err()
 	}
 
-	s.Shutdown()
+	// Longer windows are rejected
+	assert.ErrorIs(t, s.Schedule(NewTask(now, now.Add(MaxTaskDuration+time.Second), waitForDeadline, nil)), ErrTaskMaxDurationExceeded)
+
+	// A task using the entire window is stopped by the deadline of its context,
+	// the next task on the same SDR starts right after it
+	ended := make(chan error, 1)
+	day := NewTask(now, now.Add(MaxTaskDuration), waitForDeadline, nil).WithID("day").WithResource(SDRDevice1)
+	day.PostExecute = func(err error) { ended <- err }
+	started := make(chan time.Time, 1)
+	next := NewTask(day.EndTime.Add(time.Second), day.EndTime.Add(time.Hour), func(_ context.Context, _ interface{}) error {
+		started <- clk.Now()
+		return nil
+	}, nil).WithID("next").WithResource(SDRDevice1)
+
+	assert.NoError(t, s.Schedule(day))
+	assert.NoError(t, s.Schedule(next))
+
+	advanceUntil(t, clk, time.Hour, func() bool { return len(ended) == 1 })
+	assert.ErrorIs(t, <-ended, context.DeadlineExceeded)
+	assert.False(t, clk.Now().Before(day.EndTime))
+
+	advanceUntil(t, clk, time.Minute, func() bool { return len(started) == 1 })
+	assert.False(t, (<-started).Before(next.StartTime))
 }
 
 func TestSchedulerSpam(t *testing.T) {
@@ -162,3 +194,67 @@ func TestSchedulerWithResources(t *testing.T) {
 	err = s.Schedule(task4)
 	assert.ErrorIs(t, err, nil)
 }
+
+func TestSchedulerPreciseStart(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(1)
+	go s.Run()
+	defer s.Shutdown()
+
+	started := make(chan time.Time, 1)
+	startTime := time.Now().Add(150 * time.Millisecond)
+	task := NewTask(startTime, startTime.Add(time.Second), func(_ context.Context, _ interface{}) error {
+		started <- time.Now()
+		return nil
+	}, nil)
+
+	// Scheduling after an idle period has to re-arm the timer
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, s.Schedule(task))
+
+	select {
+	case at := <-started:
+		assert.False(t, at.Before(startTime), "task started early")
+		assert.Less(t, at.Sub(startTime), 20*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Error("Timeout waiting for task to execute")
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(2)
+	go s.Run()
+	defer s.Shutdown()
+
+	cause := make(chan error, 1)
+	running := NewTask(time.Now(), time.Now().Add(time.Hour), func(ctx context.Context, _ interface{}) error {
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return ctx.Err()
+	}, nil).WithID("running")
+	assert.NoError(t, s.Schedule(running))
+	assert.Eventually(t, s.HasRunningJob, time.Second, 10*time.Millisecond)
+
+	queued := NewTask(time.Now().Add(time.Hour), time.Now().Add(2*time.Hour), func(_ context.Context, _ interface{}) error { return nil }, nil).WithID("queued")
+	assert.NoError(t, s.Schedule(queued))
+
+	// Queued tasks are removed, running ones learn why they were stopped
+	assert.True(t, s.Cancel("queued"))
+	assert.True(t, s.Cancel("running"))
+	assert.False(t, s.Cancel("unknown"))
+
+	select {
+	case err := <-cause:
+		assert.ErrorIs(t, err, ErrTaskCancelled)
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("running task was not cancelled")
+	}
+
+	assert.Eventually(t, func() bool { return len(s.Snapshot().History) == 2 }, time.Second, 10*time.Millisecond)
+	for _, info := range s.Snapshot().History {
+		assert.Equal(t, StateCancelled, info.State)
+	}
+	assert.Empty(t, s.Snapshot().Queued)
+}
@@ -0,0 +1,253 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of ended tasks kept in the history
+	HistorySize = 100
+)
+
+// TaskState describes where a task is in its lifecycle
+type TaskState string
+
+const (
+	StateQueued    TaskState = "queued"
+	StateRunning   TaskState = "running"
+	StateSucceeded TaskState = "succeeded"
+	StateFailed    TaskState = "failed"
+	StateRetrying  TaskState = "retrying"
+	StateCancelled TaskState = "cancelled"
+	StatePreempted TaskState = "preempted"
+	StateExpired   TaskState = "expired"
+	StateSkipped   TaskState = "skipped"
+	StateRejected  TaskState = "rejected"
+)
+
+// TaskInfo is a point in time view of a task
+type TaskInfo struct {
+	ID           string         `json:"id"`
+	State        TaskState      `json:"state"`
+	StartTime    time.Time      `json:"start_time"`
+	EndTime      time.Time      `json:"end_time"`
+	Priority     int            `json:"priority,omitempty"`
+	Resources    ResourceClaims `json:"resources,omitempty"`
+	Dependencies []Dependency   `json:"dependencies,omitempty"`
+	Attempt      int            `json:"attempt,omitempty"`
+
+	// Why a queued task did not start yet
+	Reason string `json:"reason,omitempty"`
+
+	// When the task was first queued
+	ScheduledAt time.Time `json:"scheduled_at,omitempty"`
+
+	// Set once the task started
+	StartedAt      time.Time `json:"started_at,omitempty"`
+	ElapsedSeconds float64   `json:"elapsed_s,omitempty"`
+
+	// Set once the task ended
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Snapshot is a consistent view of the scheduler state
+type Snapshot struct {
+	Time       time.Time  `json:"time"`
+	Workers    int        `json:"workers"`
+	Capacities Capacities `json:"capacities"`
+	Queued     []TaskInfo `json:"queued"`
+	Running    []TaskInfo `json:"running"`
+	Recurring  []TaskInfo `json:"recurring,omitempty"`
+	TimeSynced bool       `json:"time_synced"`
+	// Set once the scheduler drains before a shutdown
+	Draining      bool      `json:"draining,omitempty"`
+	DrainDeadline time.Time `json:"drain_deadline,omitempty"`
+	// Ended tasks, the most recent one last
+	History []TaskInfo `json:"history"`
+}
+
+// stateOf maps the result of an ended task to its state
+func stateOf(err error) TaskState {
+	switch {
+	case err == nil:
+		return StateSucceeded
+	case errors.Is(err, ErrTaskPreempted):
+		return StatePreempted
+	case errors.Is(err, context.Canceled):
+		return StateCancelled
+	case errors.Is(err, ErrTaskExpired):
+		return StateExpired
+	case errors.Is(err, ErrDependencyFailed):
+		return StateSkipped
+	case errors.Is(err, ErrResourceSharingNotPossible):
+		return StateRejected
+	default:
+		return StateFailed
+	}
+}
+
+func (t *Task) info(state TaskState, now time.Time) TaskInfo {
+	info := TaskInfo{
+		ID:           t.id,
+		State:        state,
+		StartTime:    t.StartTime,
+		EndTime:      t.EndTime,
+		Priority:     t.Priority,
+		Resources:    t.resources.sorted(),
+		Dependencies: t.dependencies,
+		Attempt:      t.attempt,
+		ScheduledAt:  t.scheduledAt,
+		StartedAt:    t.startedAt,
+	}
+
+	if !t.startedAt.IsZero() {
+		info.ElapsedSeconds = now.Sub(t.startedAt).Seconds()
+	}
+
+	return info
+}
+
+// remember adds an ended task to the history, must be called with the lock held
+func (s *Scheduler) remember(t *Task, err error, state TaskState) {
+	now := s.clock.Now()
+	info := t.info(state, now)
+	info.FinishedAt = now
+	if err != nil {
+		info.Error = err.Error()
+	}
+
+	s.history = append(s.history, info)
+	if len(s.history) > HistorySize {
+		s.history = s.history[len(s.history)-HistorySize:]
+	}
+}
+
+// reason explains why a queued task did not start yet, must be called with the lock held
+func (s *Scheduler) reason(t *Task, now time.Time) string {
+	if t.StartTime.After(now) {
+		return "waiting for the start time"
+	}
+
+	if ready, err := s.dependenciesMet(t); err != nil || !ready {
+		return "waiting for dependencies"
+	}
+
+	if !s.capacities.fits(t, s.running) {
+		blocking := make([]string, 0)
+		for _, r := range s.running {
+			if r.competes(t) {
+				blocking = append(blocking, r.id)
+			}
+		}
+		return fmt.Sprintf("resources in use by %s", strings.Join(blocking, ", "))
+	}
+
+	if s.draining {
+		return "scheduler is draining"
+	}
+
+	if t.timeCritical && !s.timeSynced {
+		return "waiting for time sync"
+	}
+
+	if len(s.workers) == cap(s.workers) {
+		return "no free worker"
+	}
+
+	return "starting"
+}
+
+// Snapshot returns the queued, running and recently ended tasks
+func (s *Scheduler) Snapshot() Snapshot {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	now := s.clock.Now()
+	snap := Snapshot{
+		Time:       now,
+		Workers:    cap(s.workers),
+		Capacities: maps.Clone(s.capacities),
+		Queued:     make([]TaskInfo, 0, len(s.queue)),
+		Running:    make([]TaskInfo, 0, len(s.running)),
+		Recurring:  make([]TaskInfo, 0, len(s.recurring)),
+		History:    append([]TaskInfo{}, s.history...),
+
+		TimeSynced:    s.timeSynced,
+		Draining:      s.draining,
+		DrainDeadline: s.drainDeadline,
+	}
+
+	for _, t := range s.queue {
+		info := t.info(StateQueued, now)
+		info.Reason = s.reason(t, now)
+		snap.Queued = append(snap.Queued, info)
+	}
+
+	for _, t := range s.running {
+		info := t.info(StateRunning, now)
+		if t.preempted {
+			info.Reason = "preempted, waiting for the task to return"
+		}
+		snap.Running = append(snap.Running, info)
+	}
+
+	for _, r := range s.recurring {
+		snap.Recurring = append(snap.Recurring, TaskInfo{
+			ID:        r.id,
+			State:     StateQueued,
+			StartTime: r.StartTime,
+			EndTime:   r.Until,
+			Priority:  r.Priority,
+			Resources: r.resources.sorted(),
+		})
+	}
+
+	sortInfos(snap.Queued)
+	sortInfos(snap.Recurring)
+	return snap
+}
+
+// UpcomingTask is a queued task that starts soon
+type UpcomingTask struct {
+	ID        string
+	StartTime time.Time
+	EndTime   time.Time
+	Argument  interface{}
+}
+
+// Upcoming returns the queued tasks that start within the given duration, due tasks that wait for a worker,
+// resources or their dependencies are included
+func (s *Scheduler) Upcoming(within time.Duration) []UpcomingTask {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	horizon := s.clock.Now().Add(within)
+	upcoming := make([]UpcomingTask, 0)
+	for _, t := range s.queue {
+		if t.StartTime.After(horizon) {
+			continue
+		}
+
+		upcoming = append(upcoming, UpcomingTask{ID: t.id, StartTime: t.StartTime, EndTime: t.EndTime, Argument: t.Argument})
+	}
+
+	slices.SortStableFunc(upcoming, func(a, b UpcomingTask) int { return a.StartTime.Compare(b.StartTime) })
+	return upcoming
+}
+
+// sortInfos orders the tasks by start time
+func sortInfos(infos []TaskInfo) {
+	slices.SortStableFunc(infos, func(a, b TaskInfo) int {
+		if c := a.StartTime.Compare(b.StartTime); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	log.Init(true)
+	s := NewScheduler(2)
+	go s.Run()
+	defer s.Shutdown()
+
+	release := make(chan struct{})
+	capture := NewTask(time.Now(), time.Now().Add(time.Minute), func(ctx context.Context, _ interface{}) error {
+		<-release
+		return errors.New("sdr vanished")
+	}, nil).WithID("capture").WithResource(SDRDevice1)
+	assert.NoError(t, s.Schedule(capture))
+	assert.Eventually(t, s.HasRunningJob, time.Second, 10*time.Millisecond)
+
+	// One task waits for the capture, the other one for its start time
+	upload := NewTask(time.Now(), time.Now().Add(time.Minute), func(_ context.Context, _ interface{}) error { return nil }, nil).
+		WithID("upload").WithDependency("capture", OnCompletion)
+	later := NewTask(time.Now().Add(time.Hour), time.Now().Add(2*time.Hour), func(_ context.Context, _ interface{}) error { return nil }, nil).
+		WithID("later").WithResource(SDRDevice1)
+	assert.NoError(t, s.Schedule(upload))
+	assert.NoError(t, s.Schedule(later))
+
+	// A conflicting task is rejected and shows up in the history
+	assert.ErrorIs(t, s.Schedule(NewTask(time.Now().Add(time.Hour), time.Now().Add(2*time.Hour), func(_ context.Context, _ interface{}) error { return nil }, nil).
+		WithID("conflict").WithResource(SDRDevice1)), ErrResourceSharingNotPossible)
+
+	snap := s.Snapshot()
+	assert.Equal(t, 2, snap.Workers)
+	assert.Len(t, snap.Running, 1)
+	assert.Equal(t, "capture", snap.Running[0].ID)
+	assert.Equal(t, 1, snap.Running[0].Attempt)
+	assert.Equal(t, ResourceClaims{SDRDevice1}, snap.Running[0].Resources)
+	assert.Greater(t, snap.Running[0].ElapsedSeconds, 0.0)
+
+	assert.Len(t, snap.Queued, 2)
+	assert.Equal(t, "upload", snap.Queued[0].ID)
+	assert.Equal(t, "waiting for dependencies", snap.Queued[0].Reason)
+	assert.Equal(t, "later", snap.Queued[1].ID)
+	assert.Equal(t, "waiting for the start time", snap.Queued[1].Reason)
+
+	// Only the waiting upload starts within the next minutes
+	upcoming := s.Upcoming(5 * time.Minute)
+	assert.Len(t, upcoming, 1)
+	assert.Equal(t, "upload", upcoming[0].ID)
+	assert.Len(t, s.Upcoming(2*time.Hour), 2)
+
+	assert.Len(t, snap.History, 1)
+	assert.Equal(t, StateRejected, snap.History[0].State)
+	assert.Equal(t, ErrResourceSharingNotPossible.Error(), snap.History[0].Error)
+
+	close(release)
+	assert.Eventually(t, func() bool { return len(s.Snapshot().History) == 3 }, time.Second, 10*time.Millisecond)
+
+	snap = s.Snapshot()
+	assert.Equal(t, "capture", snap.History[1].ID)
+	assert.Equal(t, StateFailed, snap.History[1].State)
+	assert.Equal(t, "sdr vanished", snap.History[1].Error)
+	assert.Equal(t, "upload", snap.History[2].ID)
+	assert.Equal(t, StateSucceeded, snap.History[2].State)
+}
+
+func TestHistoryBounded(t *testing.T) {
+	s := NewScheduler(1)
+	task := NewTask(time.Now(), time.Now(), nil, nil)
+	for i := 0; i < HistorySize+10; i++ {
+		s.remember(task, nil, StateSucceeded)
+	}
+	assert.Len(t, s.Snapshot().History, HistorySize)
+}
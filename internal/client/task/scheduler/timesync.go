@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/LeoCommon/client/pkg/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// The run loop wakes up at least this often to detect steps of the wall clock
+	ClockCheckInterval = 10 * time.Second
+	// Differences between the wall and the monotonic clock below this are drift, not steps
+	ClockStepThreshold = 2 * time.Second
+)
+
+// WithTimeCritical lets the task only start once the time is synchronized, see SetTimeSynced
+func (t *Task) WithTimeCritical() *Task {
+	t.timeCritical = true
+	return t
+}
+
+// SetTimeSynced tells the scheduler if the wall clock can be trusted, time critical tasks are held back until it can
+func (s *Scheduler) SetTimeSynced(synced bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.timeSynced == synced {
+		return
+	}
+
+	log.Info("time synchronization changed", zap.Bool("synced", synced))
+	s.timeSynced = synced
+	s.notify()
+}
+
+// checkClock detects steps of the wall clock by comparing it to the monotonic clock.
+// Queued tasks are re-evaluated by the following tick anyway, the deadlines of running tasks are re-armed.
+// Only called from the run loop
+func (s *Scheduler) checkClock() {
+	// Times with a monotonic reading would compare the monotonic clocks
+	wall, mono := s.clock.Now().Round(0), s.clock.Monotonic()
+	step := wall.Sub(s.lastWall) - (mono - s.lastMono)
+	s.lastWall, s.lastMono = wall, mono
+
+	if step.Abs() < ClockStepThreshold {
+		return
+	}
+
+	log.Warn("wall clock was stepped, re-evaluating the task windows", zap.Duration("step", step))
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, t := range s.running {
+		if t.deadline != nil {
+			t.deadline.Rearm()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LeoCommon/client/pkg/clock"
+	"github.com/LeoCommon/client/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockStep(t *testing.T) {
+	log.Init(true)
+	// The RTC was wrong on boot
+	clk := clock.NewFake(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := NewScheduler(2).WithClock(clk)
+	go s.Run()
+	defer s.Shutdown()
+
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	started := make(chan struct{}, 1)
+	deadline := make(chan error, 1)
+	expired := make(chan error, 1)
+
+	capture := NewTask(now.Add(-time.Minute), now.Add(time.Hour), func(ctx context.Context, _ interface{}) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil).WithID("capture")
+	capture.PostExecute = func(err error) { deadline <- err }
+	missed := NewTask(now.Add(-2*time.Hour), now.Add(-time.Hour), func(_ context.Context, _ interface{}) error { return nil }, nil).WithID("missed")
+	missed.PostExecute = func(err error) { expired <- err }
+	assert.NoError(t, s.Schedule(capture))
+	assert.NoError(t, s.Schedule(missed))
+
+	// The time sync steps the clock by years, the tasks are re-evaluated right away
+	clk.Set(now)
+	advanceUntil(t, clk, time.Second, func() bool { return len(started) == 1 && len(expired) == 1 })
+	assert.Less(t, clk.Monotonic(), ClockCheckInterval+time.Minute)
+	assert.ErrorIs(t, <-expired, ErrTaskExpired)
+
+	// Stepping past the end of the running task ends it, its timer alone would fire an hour later
+	clk.Set(clk.Now().Add(2 * time.Hour))
+	advanceUntil(t, clk, time.Second, func() bool { return len(deadline) == 1 })
+	assert.ErrorIs(t, <-deadline, context.DeadlineExceeded)
+	assert.Less(t, clk.Monotonic(), 2*ClockCheckInterval+time.Minute)
+}
+
+func TestTimeSync(t *testing.T) {
+	log.Init(true)
+	clk := clock.NewFake(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC))
+	s := NewScheduler(2).WithClock(clk)
+	s.SetTimeSynced(false)
+	go s.Run()
+	defer s.Shutdown()
+
+	now := clk.Now()
+	ran := make(chan string, 2)
+	run := func(_ context.Context, arg interface{}) error {
+		ran <- arg.(string)
+		return nil
+	}
+
+	// Only the time critical task waits for the time sync
+	assert.NoError(t, s.Schedule(NewTask(now, now.Add(time.Hour), run, "capture").WithID("capture").WithTimeCritical()))
+	assert.NoError(t, s.Schedule(NewTask(now, now.Add(time.Hour), run, "status").WithID("status")))
+	assert.Equal(t, "status", <-ran)
+
+	snap := s.Snapshot()
+	assert.False(t, snap.TimeSynced)
+	assert.Len(t, snap.Queued, 1)
+	assert.Equal(t, "waiting for time sync", snap.Queued[0].Reason)
+
+	s.SetTimeSynced(true)
+	assert.Equal(t, "capture", <-ran)
+}
+
+// monotonicClock returns times with a monotonic reading like time.Now does
+type monotonicClock struct {
+	*clock.Fake
+	start time.Time
+}
+
+func (c monotonicClock) Now() time.Time {
+	return c.start.Add(c.Fake.Now().Sub(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestClockStepMonotonicReading(t *testing.T) {
+	log.Init(true)
+	clk := monotonicClock{Fake: clock.NewFake(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)), start: time.Now()}
+	assert.Contains(t, clk.Now().String(), "m=")
+
+	// Sub of two times with monotonic readings measures the monotonic clock, the steps would always be 0
+	s := NewScheduler(1).WithClock(clk)
+	s.checkClock()
+	assert.False(t, strings.Contains(s.lastWall.String(), "m="), s.lastWall.String())
+}
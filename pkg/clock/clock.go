@@ -0,0 +1,114 @@
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provides the current time and timers, so time dependent code can be tested with a Fake
+type Clock interface {
+	// Now returns the wall clock time, it jumps if the system clock is stepped
+	Now() time.Time
+	// Monotonic returns the time elapsed since an arbitrary point, it is not affected by steps
+	Monotonic() time.Duration
+	// Timers measure durations on the monotonic clock
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the part of time.Timer the clocks provide
+type Timer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// Real is the system clock
+var Real Clock = &realClock{start: time.Now()}
+
+type realClock struct {
+	start time.Time
+}
+
+// Now strips the monotonic reading, otherwise time.Time.Sub would measure the monotonic clock and hide the steps
+func (*realClock) Now() time.Time {
+	return time.Now().Round(0)
+}
+
+func (c *realClock) Monotonic() time.Duration {
+	return time.Since(c.start)
+}
+
+func (*realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{time.NewTimer(d)}
+}
+
+func (*realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return &realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// Deadline cancels its context once the wall clock reached a point in time
+type Deadline struct {
+	clock  Clock
+	at     time.Time
+	timer  Timer
+	cancel context.CancelCauseFunc
+}
+
+// WithDeadline is context.WithDeadline measured by the given clock. The timer runs on the monotonic clock,
+// so the deadline has to be re-armed after the wall clock was stepped
+func WithDeadline(parent context.Context, c Clock, at time.Time) (context.Context, *Deadline) {
+	ctx, cancel := context.WithCancelCause(parent)
+	d := &Deadline{clock: c, at: at, cancel: cancel}
+	d.timer = c.AfterFunc(at.Sub(c.Now()), d.expire)
+
+	return &deadlineCtx{Context: ctx, d: d}, d
+}
+
+// WithTimeout is context.WithTimeout measured by the given clock
+func WithTimeout(parent context.Context, c Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, d := WithDeadline(parent, c, c.Now().Add(timeout))
+	return ctx, d.Stop
+}
+
+func (d *Deadline) expire() {
+	d.cancel(context.DeadlineExceeded)
+}
+
+// Rearm re-calculates the remaining time from the current wall clock time
+func (d *Deadline) Rearm() {
+	d.timer.Reset(d.at.Sub(d.clock.Now()))
+}
+
+// Stop cancels the context and releases the timer
+func (d *Deadline) Stop() {
+	d.timer.Stop()
+	d.cancel(context.Canceled)
+}
+
+// deadlineCtx reports the deadline of a context that is cancelled by a clock timer
+type deadlineCtx struct {
+	context.Context
+	d *Deadline
+}
+
+func (c *deadlineCtx) Deadline() (time.Time, bool) {
+	return c.d.at, true
+}
+
+func (c *deadlineCtx) Err() error {
+	err := c.Context.Err()
+	if err != nil && context.Cause(c.Context) == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+
+	return err
+}
@@ -0,0 +1,80 @@
+package clock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeTimer(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	timer := f.NewTimer(time.Hour)
+	f.Advance(59 * time.Minute)
+	assert.Empty(t, timer.C())
+
+	f.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Hour), <-timer.C())
+
+	// Stopped timers never fire
+	timer.Reset(time.Minute)
+	assert.True(t, timer.Stop())
+	f.Advance(time.Hour)
+	assert.Empty(t, timer.C())
+
+	// Timers measure the elapsed time, steps of the wall clock do not affect them
+	timer.Reset(time.Minute)
+	f.Set(start.Add(24 * time.Hour))
+	assert.Empty(t, timer.C())
+	assert.Equal(t, 2*time.Hour, f.Monotonic())
+	f.Advance(time.Minute)
+	assert.Len(t, timer.C(), 1)
+}
+
+func TestWithTimeout(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	ctx, cancel := WithTimeout(context.Background(), f, 24*time.Hour)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(24*time.Hour), deadline)
+
+	f.BlockUntil(1)
+	f.Advance(24 * time.Hour)
+	<-ctx.Done()
+	assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+
+	// Cancelling before the deadline is a regular cancellation
+	ctx, cancel = WithTimeout(context.Background(), f, time.Hour)
+	cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestDeadlineRearm(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	ctx, deadline := WithDeadline(context.Background(), f, start.Add(time.Hour))
+	defer deadline.Stop()
+
+	// The clock is stepped past the deadline, the timer alone would fire an hour later
+	f.Set(start.Add(2 * time.Hour))
+	assert.NoError(t, ctx.Err())
+
+	deadline.Rearm()
+	<-ctx.Done()
+	assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+}
+
+func TestRealNow(t *testing.T) {
+	// A monotonic reading ("m=...") would make Sub ignore steps of the wall clock
+	now := Real.Now()
+	assert.False(t, strings.Contains(now.String(), "m="), now.String())
+}
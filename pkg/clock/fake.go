@@ -0,0 +1,156 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a manually advanced clock for tests, its timers fire once the fake monotonic time reaches them
+type Fake struct {
+	m      sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	mono   time.Duration
+	timers []*fakeTimer
+}
+
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.m)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.now
+}
+
+func (f *Fake) Monotonic() time.Duration {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.mono
+}
+
+// Advance lets time pass and fires all timers that are due
+func (f *Fake) Advance(d time.Duration) {
+	f.m.Lock()
+	f.now = f.now.Add(d)
+	f.mono += d
+	f.m.Unlock()
+
+	f.fire()
+}
+
+// Set steps the wall clock to the given time, like a stepped system clock it can also go backwards.
+// As with the real clock, timers are not affected
+func (f *Fake) Set(now time.Time) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.now = now
+}
+
+// BlockUntil waits until at least n timers are pending, e.g. until a goroutine armed its timer
+func (f *Fake) BlockUntil(n int) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	for len(f.timers) < n {
+		f.cond.Wait()
+	}
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{f: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{f: f, fn: fn}
+	t.Reset(d)
+	return t
+}
+
+// fire triggers the due timers outside the lock, so they can call back into the clock
+func (f *Fake) fire() {
+	f.m.Lock()
+	due := make([]*fakeTimer, 0)
+	pending := f.timers[:0]
+	for _, t := range f.timers {
+		if t.deadline > f.mono {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	f.timers = pending
+	now := f.now
+	f.m.Unlock()
+
+	for _, t := range due {
+		t.trigger(now)
+	}
+}
+
+// remove stops the timer, must be called with the lock held
+func (f *Fake) remove(t *fakeTimer) bool {
+	for i, p := range f.timers {
+		if p == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+type fakeTimer struct {
+	f        *Fake
+	c        chan time.Time
+	fn       func()
+	deadline time.Duration
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.f.m.Lock()
+	active := t.f.remove(t)
+	t.deadline = t.f.mono + d
+	t.f.timers = append(t.f.timers, t)
+	t.f.cond.Broadcast()
+	t.f.m.Unlock()
+
+	// Timers without duration fire right away
+	if d <= 0 {
+		t.f.fire()
+	}
+
+	return active
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.f.m.Lock()
+	defer t.f.m.Unlock()
+
+	return t.f.remove(t)
+}
+
+func (t *fakeTimer) trigger(now time.Time) {
+	if t.fn != nil {
+		go t.fn()
+		return
+	}
+
+	// Like time.Timer the channel holds at most one tick
+	select {
+	case t.c <- now:
+	default:
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/LeoCommon/client/pkg/log"
 	"go.uber.org/zap"
@@ -226,3 +227,13 @@ func GetFileSize(filePath string) (int, error) {
 	fileSize := int(theFile.Size())
 	return fileSize, nil
 }
+
+// FreeSpace returns the bytes available to unprivileged users on the filesystem of path
+func FreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
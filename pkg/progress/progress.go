@@ -0,0 +1,85 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Update describes how far a running job got
+type Update struct {
+	// What the job is doing, e.g. "capture" or "upload"
+	Stage string
+	// Progress of the stage between 0 and 100, negative if unknown
+	Percent float64
+	// Optional details, e.g. "chunk 7/30"
+	Message string
+}
+
+// String formats the update for a job status, spaces are not allowed there
+func (u Update) String() string {
+	parts := []string{u.Stage}
+	if u.Percent >= 0 {
+		parts = append(parts, fmt.Sprintf("%.0f%%", min(u.Percent, 100)))
+	}
+	if len(u.Message) != 0 {
+		parts = append(parts, u.Message)
+	}
+
+	return strings.ReplaceAll(strings.Join(parts, ":"), " ", "_")
+}
+
+// Reporter receives the progress of a running job
+type Reporter interface {
+	Report(u Update)
+}
+
+// ReporterFunc allows using a function as Reporter
+type ReporterFunc func(u Update)
+
+func (f ReporterFunc) Report(u Update) {
+	f(u)
+}
+
+type reporterKey struct{}
+
+// WithReporter returns a context that passes the updates of the job to r
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// Report passes the update to the reporter of the context, it does nothing if there is none
+func Report(ctx context.Context, u Update) {
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		r.Report(u)
+	}
+}
+
+// Throttle forwards at most one update per interval, a change of the stage is always forwarded
+type Throttle struct {
+	m        sync.Mutex
+	next     Reporter
+	interval time.Duration
+	last     time.Time
+	stage    string
+}
+
+func NewThrottle(next Reporter, interval time.Duration) *Throttle {
+	return &Throttle{next: next, interval: interval}
+}
+
+func (t *Throttle) Report(u Update) {
+	t.m.Lock()
+	now := time.Now()
+	if u.Stage == t.stage && now.Sub(t.last) < t.interval {
+		t.m.Unlock()
+		return
+	}
+	t.last = now
+	t.stage = u.Stage
+	t.m.Unlock()
+
+	t.next.Report(u)
+}
@@ -0,0 +1,41 @@
+package progress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateString(t *testing.T) {
+	assert.Equal(t, "capture:42%", Update{Stage: "capture", Percent: 42.4}.String())
+	assert.Equal(t, "upload:100%:chunk_7/30", Update{Stage: "upload", Percent: 101, Message: "chunk 7/30"}.String())
+	assert.Equal(t, "zip", Update{Stage: "zip", Percent: -1}.String())
+}
+
+func TestReport(t *testing.T) {
+	// Without reporter nothing happens
+	Report(context.Background(), Update{Stage: "capture"})
+
+	var got []Update
+	ctx := WithReporter(context.Background(), ReporterFunc(func(u Update) { got = append(got, u) }))
+	Report(ctx, Update{Stage: "capture", Percent: 10})
+	assert.Equal(t, []Update{{Stage: "capture", Percent: 10}}, got)
+}
+
+func TestThrottle(t *testing.T) {
+	var got []Update
+	throttle := NewThrottle(ReporterFunc(func(u Update) { got = append(got, u) }), 50*time.Millisecond)
+
+	throttle.Report(Update{Stage: "capture", Percent: 1})
+	throttle.Report(Update{Stage: "capture", Percent: 2})
+	// A new stage is passed on right away
+	throttle.Report(Update{Stage: "upload", Percent: 0})
+	assert.Len(t, got, 2)
+
+	time.Sleep(60 * time.Millisecond)
+	throttle.Report(Update{Stage: "upload", Percent: 50})
+	assert.Len(t, got, 3)
+	assert.Equal(t, 50.0, got[2].Percent)
+}
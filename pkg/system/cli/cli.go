@@ -33,6 +33,24 @@ func GetTimingStatus() (string, error) {
 	return string(trackingOut) + "\n" + string(sourcesOut), nil
 }
 
+// IsTimeSynchronized asks chrony if the system time is synchronized
+func IsTimeSynchronized() (bool, error) {
+	out, err := exec.Command("chronyc", "tracking").Output()
+	if err != nil {
+		return false, err
+	}
+
+	// e.g. "Leap status     : Normal" or "Leap status     : Not synchronised"
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if found && strings.TrimSpace(key) == "Leap status" {
+			return strings.TrimSpace(value) != "Not synchronised", nil
+		}
+	}
+
+	return false, fmt.Errorf("no leap status in chronyc output")
+}
+
 func GetSystemdStatus() (string, error) {
 	out, err := exec.Command("systemctl", "status").Output()
 	if err != nil {
@@ -18,6 +18,9 @@ const (
 	ModemSIM7600
 )
 
+// The device types that can capture, see scheduler.SDRDevice
+var SDRTypes = []DeviceType{SDRHackRFOne, SDRHackRFJawbreaker}
+
 // fixme required usb permissions for accessing the modem
 var (
 	SupportedDevices = DeviceMap{
@@ -3,6 +3,7 @@ package usb
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 
@@ -26,6 +27,8 @@ type USBDeviceManager struct {
 	udevCloseChannel chan struct{}
 	// The udev event connection, if not nil, udev monitoring is active
 	udev *netlink.UEventConn
+	// Called for attached and removed USB drive partitions
+	storageListeners []StorageListener
 }
 
 func NewUSBDeviceManager() *USBDeviceManager {
@@ -98,6 +101,42 @@ func (m *USBDeviceManager) HotplugReceived(VendorID uint16, productID uint16, wa
 	}
 }
 
+// Devices returns the attached supported devices
+func (m *USBDeviceManager) Devices() []Device {
+	m.Lock()
+	defer m.Unlock()
+
+	devices := make([]Device, 0, len(m.devices))
+	for _, d := range m.devices {
+		devices = append(devices, *d)
+	}
+	slices.SortFunc(devices, func(a, b Device) int { return strings.Compare(a.Name, b.Name) })
+
+	return devices
+}
+
+// Attached returns true if a device of any of the types is attached
+func (m *USBDeviceManager) Attached(types ...DeviceType) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, t := range types {
+		if _, ok := m.devices[t]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HotplugActive returns true if devices are tracked while attached and removed
+func (m *USBDeviceManager) HotplugActive() bool {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.udev != nil
+}
+
 func (m *USBDeviceManager) Shutdown() {
 	m.Lock()
 
@@ -174,6 +213,7 @@ func (m *USBDeviceManager) monitor() {
 					"DEVTYPE": "usb_device",
 				},
 			},
+			storageMatcher(),
 		},
 	}
 
@@ -201,6 +241,11 @@ udevMonitorLoop:
 
 		case uevent := <-queue:
 			// log.Debug("event", zap.Any("ev", uevent.Env))
+			if uevent.Env["SUBSYSTEM"] == "block" {
+				m.storageReceived(uevent)
+				continue
+			}
+
 			pstr, pok := uevent.Env["PRODUCT"]
 			if !pok {
 				log.Debug("device did not contain product indicator", zap.Any("env", uevent.String()))
@@ -0,0 +1,145 @@
+package usb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/DiscoResearchSat/go-udev/netlink"
+	"github.com/LeoCommon/client/pkg/log"
+	"go.uber.org/zap"
+)
+
+// Udev creates these links for the partitions of USB drives
+const usbPartitionGlob = "/dev/disk/by-id/usb-*-part*"
+
+// Filesystems of USB drives that are mounted, tried in this order if udev did not report one
+var SupportedFilesystems = []string{"vfat", "exfat", "ext4", "ext3", "ext2"}
+
+var (
+	ErrUnsupportedFilesystem = errors.New("filesystem not supported")
+	// The process lacks CAP_SYS_ADMIN, see dist/systemd/client.service.d/removable-spool.conf
+	ErrMountNotPermitted = errors.New("mounting needs CAP_SYS_ADMIN")
+)
+
+// StorageDevice is a partition of a USB drive, e.g. a USB stick
+type StorageDevice struct {
+	// e.g. /dev/sda1
+	DevName string
+	Label   string
+	// Empty if unknown
+	FSType string
+}
+
+// StorageListener is called when a USB drive partition is attached or removed
+type StorageListener func(dev StorageDevice, added bool)
+
+// storageMatcher matches the udev events of USB drive partitions
+func storageMatcher() netlink.RuleDefinition {
+	action := string(netlink.ADD) + "|" + string(netlink.REMOVE)
+	return netlink.RuleDefinition{
+		Action: &action,
+		Env: map[string]string{
+			"SUBSYSTEM": "block",
+			"DEVTYPE":   "partition",
+			"ID_BUS":    "usb",
+		},
+	}
+}
+
+// OnStorage registers a listener for USB drive partitions, it is called from the udev monitor and must not block
+func (m *USBDeviceManager) OnStorage(fn StorageListener) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.storageListeners = append(m.storageListeners, fn)
+}
+
+// storageReceived forwards a USB drive partition event to the listeners
+func (m *USBDeviceManager) storageReceived(uevent netlink.UEvent) {
+	dev := StorageDevice{
+		DevName: uevent.Env["DEVNAME"],
+		Label:   uevent.Env["ID_FS_LABEL"],
+		FSType:  uevent.Env["ID_FS_TYPE"],
+	}
+	if len(dev.DevName) == 0 {
+		log.Debug("storage event without device name", zap.String("event", uevent.String()))
+		return
+	}
+	if !filepath.IsAbs(dev.DevName) {
+		dev.DevName = filepath.Join("/dev", dev.DevName)
+	}
+
+	added := uevent.Action == netlink.ADD
+	log.Info("usb storage changed", zap.String("device", dev.DevName), zap.String("label", dev.Label), zap.Bool("added", added))
+
+	m.Lock()
+	listeners := append([]StorageListener{}, m.storageListeners...)
+	m.Unlock()
+
+	for _, fn := range listeners {
+		fn(dev, added)
+	}
+}
+
+// AttachedStorage returns the USB drive partitions that were attached before the monitor started
+func AttachedStorage() []StorageDevice {
+	links, _ := filepath.Glob(usbPartitionGlob)
+
+	devices := make([]StorageDevice, 0, len(links))
+	for _, link := range links {
+		devName, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+
+		devices = append(devices, StorageDevice{DevName: devName})
+	}
+
+	return devices
+}
+
+// Mount mounts the partition at dir, executables and device files on the drive are never honored
+func (d StorageDevice) Mount(dir string) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	fsTypes := SupportedFilesystems
+	if len(d.FSType) != 0 {
+		fsTypes = []string{d.FSType}
+	}
+
+	err := error(ErrUnsupportedFilesystem)
+	for _, fsType := range fsTypes {
+		if !slices.Contains(SupportedFilesystems, strings.ToLower(fsType)) {
+			continue
+		}
+
+		err = syscall.Mount(d.DevName, dir, strings.ToLower(fsType), syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+		if err == nil {
+			return nil
+		}
+		// The other filesystems would fail the same way
+		if errors.Is(err, syscall.EPERM) {
+			return fmt.Errorf("%w: %v", ErrMountNotPermitted, err)
+		}
+	}
+
+	return err
+}
+
+// Unmount detaches a mounted drive, the drive might already be gone so this never waits for open files
+func Unmount(dir string) error {
+	err := syscall.Unmount(dir, syscall.MNT_DETACH)
+	if errors.Is(err, syscall.EINVAL) {
+		// Not mounted
+		return nil
+	}
+
+	return err
+}